@@ -3,12 +3,21 @@ package main
 import (
 	"embed"
 	"fmt"
+	"os"
 )
 
 //go:embed bin/*
 var binaryFS embed.FS
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "move-session" {
+		if err := runMoveSession(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "move-session: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	entries, err := binaryFS.ReadDir("bin")
 	if err != nil {
 		fmt.Printf("error reading binary fs: %s", err.Error())
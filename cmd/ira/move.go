@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cchirag/ira/internal/endpoint"
+	"github.com/cchirag/ira/internal/storage"
+	"go.etcd.io/bbolt"
+)
+
+var errSameWorkspace = errors.New("source and destination workspace are the same")
+
+// runMoveSession parses the arguments of "ira move-session <name> --to
+// <workspace>" and runs it.
+func runMoveSession(args []string) error {
+	flags := flag.NewFlagSet("move-session", flag.ContinueOnError)
+	from := flags.String("from", "", "workspace to move the session out of (default workspace when empty)")
+	to := flags.String("to", "", "workspace to move the session into (default workspace when empty)")
+	rename := flags.String("rename", "", "name to give the session in the destination, for when its name is taken there")
+
+	// Accept the name before or after the flags.
+	var names []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		names, args = append(names, flags.Arg(0)), flags.Args()[1:]
+	}
+
+	if len(names) != 1 {
+		return errors.New("usage: ira move-session <name> --to <workspace> [--from <workspace>] [--rename <name>]")
+	}
+
+	return moveSession(names[0], *from, *to, *rename)
+}
+
+// moveSession copies the named session tree from one workspace's db into
+// another's, then deletes it from the source. Both dbs are opened directly, so
+// neither workspace's irad may be running. The two dbs cannot share a
+// transaction: if the delete fails the session is left in both.
+func moveSession(name, from, to, rename string) error {
+	if from == to {
+		return errSameWorkspace
+	}
+
+	source, err := openWorkspace(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := openWorkspace(to)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+
+	var session storage.SessionEntry
+	var data []byte
+
+	if err := source.View(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.GetSessionByName(tx, name); err != nil {
+			return err
+		}
+		data, err = storage.ExportSession(tx, session.ID)
+		return err
+	}); err != nil {
+		return fmt.Errorf("export session %q: %w", name, err)
+	}
+
+	if rename != "" {
+		var export storage.SessionExport
+		if err := json.Unmarshal(data, &export); err != nil {
+			return err
+		}
+		export.Session.Name = rename
+
+		if data, err = json.Marshal(export); err != nil {
+			return err
+		}
+	}
+
+	if err := destination.Update(func(tx *bbolt.Tx) error {
+		_, err := storage.ImportSession(tx, data, true)
+		return err
+	}); errors.Is(err, storage.ErrSessionAlreadyExists) {
+		return fmt.Errorf("import session %q into workspace %q: %w (pass --rename to use another name)", name, to, err)
+	} else if err != nil {
+		return fmt.Errorf("import session %q into workspace %q: %w", name, to, err)
+	}
+
+	if err := source.Update(func(tx *bbolt.Tx) error {
+		return storage.DeleteSession(tx, session.ID)
+	}); err != nil {
+		return fmt.Errorf("session %q was copied to workspace %q but not deleted from the source: %w", name, to, err)
+	}
+
+	return nil
+}
+
+// openWorkspace opens a workspace's db, failing fast if its irad holds the
+// file lock.
+func openWorkspace(workspace string) (*storage.Store, error) {
+	dir, err := endpoint.DataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	path, err := storage.WorkspacePath(dir, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.OpenStore(path, &bbolt.Options{Timeout: time.Second})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("open workspace %q: %w (is its irad running?)", workspace, err)
+	}
+
+	return store, err
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/cchirag/ira/internal/endpoint"
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/cchirag/ira/internal/storage/storagetest"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// withWorkspace opens a workspace's db for the duration of fn, so its file
+// lock is released before moveSession needs it.
+func withWorkspace(t *testing.T, workspace string, fn func(db *bbolt.DB)) {
+	t.Helper()
+
+	store, err := openWorkspace(workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	fn(store.DB())
+}
+
+// exportTree runs update, when set, and returns the session's export.
+func exportTree(t *testing.T, db *bbolt.DB, id uuid.UUID, update func(tx *bbolt.Tx) error) storage.SessionExport {
+	t.Helper()
+
+	if update != nil {
+		if err := db.Update(update); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var data []byte
+	if err := db.View(func(tx *bbolt.Tx) error {
+		var err error
+		data, err = storage.ExportSession(tx, id)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var export storage.SessionExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatal(err)
+	}
+	return export
+}
+
+func TestMoveSession(t *testing.T) {
+	t.Setenv(endpoint.DataDirEnv, t.TempDir())
+
+	var seeded storagetest.Seeded
+	var want storage.SessionExport
+	withWorkspace(t, "work", func(db *bbolt.DB) {
+		seeded = storagetest.SeedSession(t, db, "project", 2, 2)
+		want = exportTree(t, db, seeded.SessionID, func(tx *bbolt.Tx) error {
+			return storage.UpdatePaneTitle(tx, seeded.SessionID, seeded.WindowIDs[0], seeded.PaneIDs[0][1], "logs")
+		})
+	})
+	withWorkspace(t, "home", func(db *bbolt.DB) {
+		storagetest.SeedSession(t, db, "project", 1, 1)
+	})
+
+	if err := runMoveSession([]string{"project", "--from", "work", "--to", "home"}); !errors.Is(err, storage.ErrSessionAlreadyExists) {
+		t.Fatalf("expected ErrSessionAlreadyExists for a taken name, got %v", err)
+	}
+
+	if err := runMoveSession([]string{"--from", "work", "project", "--to", "home", "--rename", "work-project"}); err != nil {
+		t.Fatal(err)
+	}
+
+	withWorkspace(t, "home", func(db *bbolt.DB) {
+		if err := db.View(func(tx *bbolt.Tx) error {
+			session, err := storage.GetSessionByName(tx, "work-project")
+			if err != nil {
+				return err
+			}
+			if session.ID != seeded.SessionID {
+				t.Fatalf("expected the moved session to keep its ID %s, got %s", seeded.SessionID, session.ID)
+			}
+
+			windows, err := storage.GetWindows(tx, session.ID)
+			if err != nil {
+				return err
+			}
+			if len(windows) != 2 {
+				t.Fatalf("expected 2 windows, got %d", len(windows))
+			}
+
+			panes, err := storage.GetAllPanes(tx, session.ID)
+			if err != nil {
+				return err
+			}
+			if len(panes) != 4 {
+				t.Fatalf("expected 4 panes, got %d", len(panes))
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		got := exportTree(t, db, seeded.SessionID, nil)
+		if !reflect.DeepEqual(got.Windows, want.Windows) {
+			t.Fatalf("expected the moved windows and panes to be unchanged:\nwant %+v\ngot  %+v", want.Windows, got.Windows)
+		}
+	})
+
+	withWorkspace(t, "work", func(db *bbolt.DB) {
+		if err := db.View(func(tx *bbolt.Tx) error {
+			_, err := storage.GetSession(tx, seeded.SessionID)
+			if !errors.Is(err, storage.ErrSessionNotFound) {
+				t.Fatalf("expected the session to be gone from the source, got %v", err)
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestMoveSessionSameWorkspace(t *testing.T) {
+	t.Setenv(endpoint.DataDirEnv, t.TempDir())
+
+	if err := moveSession("project", "work", "work", ""); !errors.Is(err, errSameWorkspace) {
+		t.Fatalf("expected errSameWorkspace, got %v", err)
+	}
+}
@@ -1,47 +1,116 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
-	"net"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"syscall"
 
-	"github.com/cchirag/ira/internal/services/root"
-	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/cchirag/ira/internal/daemon"
+	"github.com/cchirag/ira/internal/endpoint"
+	"github.com/cchirag/ira/internal/storage"
 	"go.etcd.io/bbolt"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
 )
 
 const PORT = ":50051"
 
-func main() {
-	configDir, err := os.UserConfigDir()
+// dbPath returns the workspace's db file in the data dir, creating the dir if
+// it does not exist yet.
+func dbPath(workspace string) (string, error) {
+	dir, err := endpoint.DataDir()
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
 	}
-	appConfigPath := filepath.Join(configDir, "ira")
-	db, err := bbolt.Open(appConfigPath, 0600, nil)
+
+	return storage.WorkspacePath(dir, workspace)
+}
+
+// check verifies the db's integrity, logging every problem, and reports
+// whether it is sound.
+func check(path string, readOnly bool) bool {
+	store, err := storage.OpenStore(path, &bbolt.Options{ReadOnly: readOnly})
 	if err != nil {
-		log.Fatalf("error opening the db: %s", err.Error())
+		log.Printf("error opening the db: %s", err.Error())
+		return false
 	}
-	defer db.Close()
+	defer store.Close()
 
-	lis, err := net.Listen("tcp", PORT)
+	problems, err := storage.CheckIntegrity(store.DB())
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		log.Printf("error checking db integrity: %s", err.Error())
+	}
+	for _, problem := range problems {
+		log.Printf("db integrity: %s", problem.Error())
+	}
+
+	if err != nil || len(problems) > 0 {
+		return false
 	}
 
-	grpcServer := grpc.NewServer()
+	log.Printf("db integrity: ok")
+	return true
+}
 
-	protov1.RegisterRootServiceServer(grpcServer, &root.Service{
-		Db: db,
-	})
-	reflection.Register(grpcServer)
+func main() {
+	metricsListen := flag.String("metrics-listen", "", "address to serve Prometheus metrics on, e.g. :9090 (disabled when empty)")
+	readOnly := flag.Bool("read-only", false, "open the db read-only and reject every mutating RPC")
+	listen := flag.String("listen", "", "address to serve gRPC on; use :0 to pick a free port (default "+PORT+", or a free port for a named workspace)")
+	workspace := flag.String("workspace", "", "serve the named workspace's db instead of the default one")
+	dataDir := flag.String("data-dir", "", "directory for the db and endpoint files (default $"+endpoint.DataDirEnv+", or the user config dir)")
+	debug := flag.Bool("debug", false, "enable debug logging, including bbolt stats after every write")
+	checkOnly := flag.Bool("check", false, "verify db integrity and exit without starting the server")
+	shutdownTimeout := flag.Duration("shutdown-timeout", daemon.DefaultShutdownTimeout, "how long to wait for open RPCs to finish on shutdown before cutting them off")
+	allowReset := flag.Bool("allow-reset", false, "allow the Reset RPC to wipe every session, window and pane")
+	auditMutations := flag.Bool("audit", false, "record every mutating RPC and its caller in the db's audit log")
+	flag.Parse()
+
+	if *debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+
+	if *dataDir != "" {
+		// Set it for the process so the endpoint file follows the db.
+		os.Setenv(endpoint.DataDirEnv, *dataDir)
+	}
+	appConfigPath, err := dbPath(*workspace)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *checkOnly {
+		if !check(appConfigPath, *readOnly) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *listen == "" {
+		*listen = PORT
+		if *workspace != "" {
+			*listen = ":0"
+		}
+	}
 
-	log.Printf("🚀 IRA gRPC server listening on port %s", PORT)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := grpcServer.Serve(lis); err != nil {
+	if err := daemon.Run(ctx, daemon.Config{
+		DBPath:          appConfigPath,
+		ReadOnly:        *readOnly,
+		Listen:          *listen,
+		Workspace:       *workspace,
+		MetricsListen:   *metricsListen,
+		ShutdownTimeout: *shutdownTimeout,
+		AllowReset:      *allowReset,
+		Audit:           *auditMutations,
+	}); err != nil {
 		log.Fatal(err.Error())
 	}
 }
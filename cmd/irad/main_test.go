@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cchirag/ira/internal/endpoint"
+	"github.com/cchirag/ira/internal/storage"
+)
+
+func TestDataDirOverride(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "data")
+	t.Setenv(endpoint.DataDirEnv, dir)
+
+	path, err := dbPath("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Fatalf("expected the db in %s, got %s", dir, path)
+	}
+
+	store, err := storage.OpenStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the db to be created in the data dir: %v", err)
+	}
+
+	endpointPath, err := endpoint.Path("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(endpointPath) != dir {
+		t.Fatalf("expected the endpoint file in %s, got %s", dir, endpointPath)
+	}
+}
@@ -0,0 +1,88 @@
+// Package audit records successful mutating RPCs in the storage audit log,
+// along with who made them.
+package audit
+
+import (
+	"context"
+
+	"github.com/cchirag/ira/internal/storage"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ActorMetadataKey is the request metadata key naming the caller. Requests
+// without it are recorded as LocalActor.
+const ActorMetadataKey = "x-ira-actor"
+
+const LocalActor = "local"
+
+type rule struct {
+	entityType string
+	entityID   func(req, resp any) string
+}
+
+// rules lists the audited methods. Anything not in it only reads.
+var rules = map[string]rule{
+	protov1.SessionService_CreateSession_FullMethodName: {"session", func(_, resp any) string {
+		return resp.(*protov1.CreateSessionResponse).GetSession().GetId()
+	}},
+	protov1.SessionService_CreateSessionFull_FullMethodName: {"session", func(_, resp any) string {
+		return resp.(*protov1.CreateSessionFullResponse).GetSession().GetId()
+	}},
+	protov1.SessionService_DeleteSession_FullMethodName: {"session", func(req, _ any) string {
+		return req.(*protov1.DeleteSessionRequest).GetSessionId()
+	}},
+	protov1.PaneService_CreatePane_FullMethodName: {"pane", func(_, resp any) string {
+		return resp.(*protov1.CreatePaneResponse).GetPane().GetId()
+	}},
+	protov1.RootService_Reset_FullMethodName: {"database", func(_, _ any) string {
+		return ""
+	}},
+	protov1.RootService_Import_FullMethodName: {"database", func(_, _ any) string {
+		return ""
+	}},
+}
+
+// Actor returns the caller named in ctx's metadata, or LocalActor.
+func Actor(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(ActorMetadataKey); len(values) > 0 && values[0] != "" {
+		return values[0]
+	}
+	return LocalActor
+}
+
+// UnaryServerInterceptor records every audited method that succeeds. The
+// entry is written in its own transaction after the handler's has committed,
+// so a failure to record it cannot undo the change; it is reported through
+// onError, which may be nil, and the response is returned as usual.
+func UnaryServerInterceptor(db *bbolt.DB, onError func(error)) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+
+		r, ok := rules[info.FullMethod]
+		if !ok {
+			return resp, nil
+		}
+
+		entry := storage.AuditEntry{
+			Method:     info.FullMethod,
+			EntityType: r.entityType,
+			EntityID:   r.entityID(req, resp),
+			Actor:      Actor(ctx),
+		}
+
+		if err := db.Update(func(tx *bbolt.Tx) error {
+			return storage.RecordAudit(tx, entry)
+		}); err != nil && onError != nil {
+			onError(err)
+		}
+
+		return resp, nil
+	}
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cchirag/ira/internal/services/session"
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/cchirag/ira/internal/storage/storagetest"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestCreateSessionIsAudited(t *testing.T) {
+	db := storagetest.OpenDB(t)
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(db, func(err error) {
+		t.Errorf("recording audit entry: %v", err)
+	})))
+	protov1.RegisterSessionServiceServer(server, &session.Service{Db: db})
+
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	client := protov1.NewSessionServiceClient(conn)
+	since := time.Now()
+
+	created, err := client.CreateSession(context.Background(), &protov1.CreateSessionRequest{Name: "audited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), ActorMetadataKey, "alice")
+	if _, err := client.DeleteSession(ctx, &protov1.DeleteSessionRequest{SessionId: created.GetSession().GetId()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reads are not audited.
+	if _, err := client.ListSessions(context.Background(), &protov1.ListSessionsRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var log []storage.AuditEntry
+	if err := db.View(func(tx *bbolt.Tx) error {
+		log, err = storage.GetAuditLog(tx, since)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(log) != 2 {
+		t.Fatalf("expected 2 audit entries, got %+v", log)
+	}
+
+	create, remove := log[0], log[1]
+	if create.Method != protov1.SessionService_CreateSession_FullMethodName || create.EntityType != "session" ||
+		create.EntityID != created.GetSession().GetId() || create.Actor != LocalActor {
+		t.Fatalf("unexpected create entry: %+v", create)
+	}
+	if remove.Method != protov1.SessionService_DeleteSession_FullMethodName || remove.Actor != "alice" {
+		t.Fatalf("unexpected delete entry: %+v", remove)
+	}
+}
@@ -0,0 +1,185 @@
+// Package daemon runs irad: it opens the db, serves the gRPC services and shuts
+// down gracefully when its context is cancelled. cmd/irad is a thin wrapper
+// around Run, so the server can also be embedded or started from tests.
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cchirag/ira/internal/audit"
+	"github.com/cchirag/ira/internal/endpoint"
+	"github.com/cchirag/ira/internal/metrics"
+	"github.com/cchirag/ira/internal/services/pane"
+	"github.com/cchirag/ira/internal/services/root"
+	"github.com/cchirag/ira/internal/services/session"
+	"github.com/cchirag/ira/internal/services/window"
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/cchirag/ira/internal/terminal"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+const metricsRefreshInterval = 15 * time.Second
+
+const DefaultShutdownTimeout = 10 * time.Second
+
+// Config describes the server Run starts; it mirrors irad's flags.
+type Config struct {
+	// DBPath is the bbolt file to serve.
+	DBPath   string
+	ReadOnly bool
+
+	// Listener, when set, is served as is. Otherwise Run listens on Listen and
+	// records the address in the endpoint file for Workspace, removing it on
+	// return.
+	Listener  net.Listener
+	Listen    string
+	Workspace string
+
+	// MetricsListen is the address to serve Prometheus metrics on. Empty
+	// disables metrics.
+	MetricsListen string
+
+	// ShutdownTimeout bounds how long open RPCs get to finish once ctx is
+	// cancelled. Zero means DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	AllowReset bool
+	Audit      bool
+}
+
+// Run serves cfg until ctx is cancelled and the server has shut down, or until
+// serving fails. A cancelled ctx is a clean stop and returns nil.
+func Run(ctx context.Context, cfg Config) error {
+	startedAt := time.Now()
+
+	store, err := storage.OpenStore(cfg.DBPath, &bbolt.Options{ReadOnly: cfg.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("error opening the db: %w", err)
+	}
+	defer store.Close()
+
+	store.SetCommitHook(storage.LogTxStats(slog.Default()))
+
+	db := store.DB()
+
+	problems, err := storage.CheckIntegrity(db)
+	if err != nil {
+		log.Printf("error checking db integrity: %s", err.Error())
+	}
+	for _, problem := range problems {
+		log.Printf("db integrity: %s", problem.Error())
+	}
+
+	if cfg.ReadOnly {
+		log.Printf("read-only: skipping db migrations")
+	} else if err := storage.RunMigrations(db); err != nil {
+		return fmt.Errorf("error migrating the db: %w", err)
+	}
+
+	lis := cfg.Listener
+	if lis == nil {
+		if lis, err = net.Listen("tcp", cfg.Listen); err != nil {
+			return err
+		}
+
+		if err := endpoint.Write(cfg.Workspace, lis.Addr()); err != nil {
+			log.Printf("error writing endpoint file: %s", err.Error())
+		}
+		defer func() {
+			if err := endpoint.Remove(cfg.Workspace); err != nil {
+				log.Printf("error removing endpoint file: %s", err.Error())
+			}
+		}()
+	}
+
+	var opts []grpc.ServerOption
+
+	if cfg.MetricsListen != "" {
+		m := metrics.New()
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(m.UnaryServerInterceptor()),
+			grpc.ChainStreamInterceptor(m.StreamServerInterceptor()),
+		)
+
+		go m.RefreshEvery(ctx, db, metricsRefreshInterval, func(err error) {
+			log.Printf("error refreshing metrics: %s", err.Error())
+		})
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+
+		metricsServer := &http.Server{Addr: cfg.MetricsListen, Handler: mux}
+		defer metricsServer.Close()
+
+		go func() {
+			log.Printf("metrics listening on %s", cfg.MetricsListen)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("metrics server: %v", err)
+			}
+		}()
+	}
+
+	if cfg.Audit {
+		opts = append(opts, grpc.ChainUnaryInterceptor(audit.UnaryServerInterceptor(db, func(err error) {
+			log.Printf("error recording audit entry: %s", err.Error())
+		})))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+
+	protov1.RegisterRootServiceServer(grpcServer, &root.Service{
+		Db:         db,
+		StartedAt:  startedAt,
+		ReadOnly:   cfg.ReadOnly,
+		AllowReset: cfg.AllowReset,
+	})
+	protov1.RegisterSessionServiceServer(grpcServer, &session.Service{
+		Db:       db,
+		Backend:  store,
+		ReadOnly: cfg.ReadOnly,
+	})
+	protov1.RegisterPaneServiceServer(grpcServer, &pane.Service{
+		Db:        db,
+		ReadOnly:  cfg.ReadOnly,
+		Terminals: terminal.NewRegistry(),
+	})
+	protov1.RegisterWindowServiceServer(grpcServer, &window.Service{
+		Db: db,
+	})
+	reflection.Register(grpcServer)
+
+	timeout := cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- grpcServer.Serve(lis)
+	}()
+
+	log.Printf("🚀 IRA gRPC server listening on %s", lis.Addr())
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+		if shutdown(grpcServer, timeout) {
+			log.Printf("shutdown: open RPCs did not finish within %s; stopped them", timeout)
+		}
+		// Serve has returned once shutdown does; collect it before the
+		// deferred Close releases the db.
+		<-served
+		return nil
+	}
+}
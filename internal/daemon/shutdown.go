@@ -0,0 +1,30 @@
+package daemon
+
+import "time"
+
+// stopper is the part of *grpc.Server that shutdown needs.
+type stopper interface {
+	GracefulStop()
+	Stop()
+}
+
+// shutdown stops server gracefully, letting in-flight RPCs finish. Streams
+// such as Attach and the Watch RPCs only end when their client goes away, so
+// if they are still open after timeout they are cut off with Stop. It reports
+// whether the forced stop was needed and returns once the server is down.
+func shutdown(server stopper, timeout time.Duration) (forced bool) {
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return false
+	case <-time.After(timeout):
+		server.Stop()
+		<-done
+		return true
+	}
+}
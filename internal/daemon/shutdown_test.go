@@ -0,0 +1,62 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestShutdownForcesStopAfterTimeout(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	// Health Watch streams until the client goes away, like Attach.
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := healthpb.NewHealthClient(conn).Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	const timeout = 50 * time.Millisecond
+	start := time.Now()
+
+	if !shutdown(server, timeout) {
+		t.Fatal("expected the open stream to force a stop")
+	}
+	if elapsed := time.Since(start); elapsed < timeout || elapsed > 5*time.Second {
+		t.Fatalf("expected shutdown to take about %s, took %s", timeout, elapsed)
+	}
+}
+
+func TestShutdownGracefulWhenIdle(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	go server.Serve(lis)
+
+	if shutdown(server, time.Second) {
+		t.Fatal("expected an idle server to stop gracefully")
+	}
+}
@@ -0,0 +1,114 @@
+// Package endpoint records the address irad is listening on so clients can
+// find the daemon without being told where it is.
+//
+// The file lives next to the db in $IRA_DATA_DIR when that is set, at
+// $XDG_RUNTIME_DIR/ira/endpoint when XDG_RUNTIME_DIR is set, and next to the
+// db in the user config dir otherwise. Each workspace has its own file,
+// suffixed with the workspace name; the default workspace ("") has none.
+package endpoint
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultAddress is dialed when no address is given and no endpoint file
+// exists.
+const DefaultAddress = "localhost:50051"
+
+// DataDirEnv names the environment variable that overrides DataDir.
+const DataDirEnv = "IRA_DATA_DIR"
+
+// DataDir returns the directory holding the db: $IRA_DATA_DIR if set, else the
+// user config dir.
+func DataDir() (string, error) {
+	if dir := os.Getenv(DataDirEnv); dir != "" {
+		return dir, nil
+	}
+
+	return os.UserConfigDir()
+}
+
+// Path returns where the endpoint file of a workspace is written.
+func Path(workspace string) (string, error) {
+	suffix := ""
+	if workspace != "" {
+		suffix = "-" + workspace
+	}
+
+	// An explicit data dir keeps everything together, so it wins over the
+	// runtime dir.
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" && os.Getenv(DataDirEnv) == "" {
+		return filepath.Join(dir, "ira", "endpoint"+suffix), nil
+	}
+
+	dataDir, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dataDir, "ira"+suffix+".endpoint"), nil
+}
+
+// Write records addr in the workspace's endpoint file, replacing any previous one.
+func Write(workspace string, addr net.Addr) error {
+	path, err := Path(workspace)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(addr.String()+"\n"), 0600)
+}
+
+// Remove deletes the workspace's endpoint file. A missing file is not an error.
+func Remove(workspace string) error {
+	path, err := Path(workspace)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Read returns the address stored in the workspace's endpoint file.
+func Read(workspace string) (string, error) {
+	path, err := Path(workspace)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Resolve picks the address a client should dial for a workspace: explicit if
+// set, else the one in the workspace's endpoint file, else DefaultAddress.
+func Resolve(workspace, explicit string) (string, error) {
+	if explicit != "" {
+		return explicit, nil
+	}
+
+	addr, err := Read(workspace)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultAddress, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,60 @@
+package endpoint
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEndpointRoundTrip(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	if err := Write("", lis.Addr()); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, err := Resolve("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != lis.Addr().String() {
+		t.Fatalf("expected %s, got %s", lis.Addr(), addr)
+	}
+
+	if addr, _ := Resolve("", "example:1"); addr != "example:1" {
+		t.Fatalf("expected explicit address to win, got %s", addr)
+	}
+
+	if err := Remove(""); err != nil {
+		t.Fatal(err)
+	}
+	if addr, err := Resolve("", ""); err != nil || addr != DefaultAddress {
+		t.Fatalf("expected %s after remove, got %s (%v)", DefaultAddress, addr, err)
+	}
+}
+
+func TestEndpointPerWorkspace(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	if err := Write("work", lis.Addr()); err != nil {
+		t.Fatal(err)
+	}
+
+	if addr, err := Resolve("work", ""); err != nil || addr != lis.Addr().String() {
+		t.Fatalf("expected %s for workspace, got %s (%v)", lis.Addr(), addr, err)
+	}
+	if addr, err := Resolve("", ""); err != nil || addr != DefaultAddress {
+		t.Fatalf("expected default workspace to be unaffected, got %s (%v)", addr, err)
+	}
+}
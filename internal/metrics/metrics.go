@@ -0,0 +1,141 @@
+package metrics
+
+// Package metrics exposes irad's Prometheus metrics: per-RPC counters and
+// latency histograms recorded by gRPC interceptors, plus gauges for the number
+// of stored sessions, windows and panes refreshed from the DB.
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+type Metrics struct {
+	registry  *prometheus.Registry
+	requests  *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+	sessions  prometheus.Gauge
+	windows   prometheus.Gauge
+	panes     prometheus.Gauge
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "ira_grpc_requests_total",
+			Help: "gRPC requests handled, by method and status code.",
+		}, []string{"method", "code"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "ira_grpc_handler_duration_seconds",
+			Help:    "Time spent in gRPC handlers, by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		sessions: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ira_sessions",
+			Help: "Number of stored sessions.",
+		}),
+		windows: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ira_windows",
+			Help: "Number of stored windows.",
+		}),
+		panes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "ira_panes",
+			Help: "Number of stored panes.",
+		}),
+	}
+
+	m.registry.MustRegister(m.requests, m.durations, m.sessions, m.windows, m.panes)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) observe(method string, start time.Time, err error) {
+	m.requests.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.durations.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observe(info.FullMethod, start, err)
+		return err
+	}
+}
+
+// Refresh recounts sessions, windows and panes from the DB.
+func (m *Metrics) Refresh(db *bbolt.DB) error {
+	var sessionCount, windowCount, paneCount int
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		sessions, err := storage.GetSessions(tx)
+		if err != nil {
+			return err
+		}
+		sessionCount = len(sessions)
+
+		for _, session := range sessions {
+			panes, err := storage.GetAllPanes(tx, session.ID)
+			if err != nil {
+				return err
+			}
+			paneCount += len(panes)
+
+			windows, err := storage.CountWindows(tx, session.ID)
+			if err != nil {
+				return err
+			}
+			windowCount += windows
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	m.sessions.Set(float64(sessionCount))
+	m.windows.Set(float64(windowCount))
+	m.panes.Set(float64(paneCount))
+
+	return nil
+}
+
+// RefreshEvery calls Refresh on every tick until ctx is done. Errors are
+// reported through onError, which may be nil.
+func (m *Metrics) RefreshEvery(ctx context.Context, db *bbolt.DB, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Refresh(db); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
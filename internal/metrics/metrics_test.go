@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cchirag/ira/internal/storage"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+)
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		session, err := storage.NewSession(tx, "metrics")
+		if err != nil {
+			return err
+		}
+		window, err := storage.NewWindow(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		_, err = storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New()
+
+	interceptor := m.UnaryServerInterceptor()
+	if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/root.v1.RootService/Ping"},
+		func(ctx context.Context, req any) (any, error) { return nil, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Refresh(db); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		`ira_grpc_requests_total{code="OK",method="/root.v1.RootService/Ping"} 1`,
+		"ira_grpc_handler_duration_seconds_bucket",
+		"ira_sessions 1",
+		"ira_windows 1",
+		"ira_panes 1",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Fatalf("expected %q in scrape output:\n%s", expected, body)
+		}
+	}
+}
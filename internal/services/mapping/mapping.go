@@ -0,0 +1,176 @@
+// Package mapping converts storage entries to and from their proto messages.
+// Every service goes through it so fields, enums and timestamps are mapped the
+// same way everywhere.
+package mapping
+
+import (
+	"fmt"
+
+	"github.com/cchirag/ira/internal/enums"
+	"github.com/cchirag/ira/internal/storage"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func SessionToProto(session storage.SessionEntry) *protov1.Session {
+	return &protov1.Session{
+		Id:        session.ID.String(),
+		Name:      session.Name,
+		Status:    session.Status.String(),
+		CreatedAt: timestamppb.New(session.CreatedAt),
+		UpdatedAt: timestamppb.New(session.UpdatedAt),
+	}
+}
+
+// SessionFromProto is the reverse of SessionToProto. Fields the proto does
+// not carry are left zero.
+func SessionFromProto(session *protov1.Session) (storage.SessionEntry, error) {
+	id, err := uuid.Parse(session.GetId())
+	if err != nil {
+		return storage.SessionEntry{}, fmt.Errorf("session id: %w", err)
+	}
+
+	status, err := enums.ToSessionStatus(session.GetStatus())
+	if err != nil {
+		return storage.SessionEntry{}, fmt.Errorf("session status: %w", err)
+	}
+
+	return storage.SessionEntry{
+		SchemaVersion: storage.CurrentSchemaVersion,
+		ID:            id,
+		Name:          session.GetName(),
+		Status:        status,
+		CreatedAt:     session.GetCreatedAt().AsTime(),
+		UpdatedAt:     session.GetUpdatedAt().AsTime(),
+	}, nil
+}
+
+// WindowToProto maps a window, turning its stored Index into the display
+// number by adding the session's base-index.
+func WindowToProto(window storage.WindowEntry, baseIndex int) *protov1.Window {
+	return &protov1.Window{
+		Id:          window.ID.String(),
+		SessionId:   window.SessionID.String(),
+		Name:        window.Name,
+		Index:       int32(window.Index + baseIndex),
+		HasActivity: window.HasActivity,
+		Cwd:         window.Cwd,
+		CreatedAt:   timestamppb.New(window.CreatedAt),
+		UpdatedAt:   timestamppb.New(window.UpdatedAt),
+	}
+}
+
+// WindowFromProto is the reverse of WindowToProto for the same baseIndex.
+func WindowFromProto(window *protov1.Window, baseIndex int) (storage.WindowEntry, error) {
+	id, err := uuid.Parse(window.GetId())
+	if err != nil {
+		return storage.WindowEntry{}, fmt.Errorf("window id: %w", err)
+	}
+
+	sessionId, err := uuid.Parse(window.GetSessionId())
+	if err != nil {
+		return storage.WindowEntry{}, fmt.Errorf("window session id: %w", err)
+	}
+
+	return storage.WindowEntry{
+		SchemaVersion: storage.CurrentSchemaVersion,
+		ID:            id,
+		Name:          window.GetName(),
+		Index:         int(window.GetIndex()) - baseIndex,
+		SessionID:     sessionId,
+		HasActivity:   window.GetHasActivity(),
+		Cwd:           window.GetCwd(),
+		CreatedAt:     window.GetCreatedAt().AsTime(),
+		UpdatedAt:     window.GetUpdatedAt().AsTime(),
+	}, nil
+}
+
+func PaneToProto(pane storage.PaneEntry) *protov1.Pane {
+	return &protov1.Pane{
+		Id:        pane.ID.String(),
+		SessionId: pane.SsessionID.String(),
+		WindowId:  pane.WindowID.String(),
+		Width:     pane.Width,
+		Height:    pane.Height,
+		X:         pane.X,
+		Y:         pane.Y,
+		Cwd:       pane.Cwd,
+		CreatedAt: timestamppb.New(pane.CreatedAt),
+		UpdatedAt: timestamppb.New(pane.UpdatedAt),
+	}
+}
+
+func PaneFromProto(pane *protov1.Pane) (storage.PaneEntry, error) {
+	id, err := uuid.Parse(pane.GetId())
+	if err != nil {
+		return storage.PaneEntry{}, fmt.Errorf("pane id: %w", err)
+	}
+
+	sessionId, err := uuid.Parse(pane.GetSessionId())
+	if err != nil {
+		return storage.PaneEntry{}, fmt.Errorf("pane session id: %w", err)
+	}
+
+	windowId, err := uuid.Parse(pane.GetWindowId())
+	if err != nil {
+		return storage.PaneEntry{}, fmt.Errorf("pane window id: %w", err)
+	}
+
+	return storage.PaneEntry{
+		SchemaVersion: storage.CurrentSchemaVersion,
+		ID:            id,
+		SsessionID:    sessionId,
+		WindowID:      windowId,
+		Width:         pane.GetWidth(),
+		Height:        pane.GetHeight(),
+		X:             pane.GetX(),
+		Y:             pane.GetY(),
+		Cwd:           pane.GetCwd(),
+		CreatedAt:     pane.GetCreatedAt().AsTime(),
+		UpdatedAt:     pane.GetUpdatedAt().AsTime(),
+	}, nil
+}
+
+var sessionEventTypes = map[storage.SessionEventType]protov1.SessionEventType{
+	storage.SessionCreated: protov1.SessionEventType_SESSION_EVENT_TYPE_CREATED,
+	storage.SessionDeleted: protov1.SessionEventType_SESSION_EVENT_TYPE_DELETED,
+	storage.SessionRenamed: protov1.SessionEventType_SESSION_EVENT_TYPE_RENAMED,
+}
+
+var windowEventTypes = map[storage.WindowEventType]protov1.WindowEventType{
+	storage.WindowCreated:  protov1.WindowEventType_WINDOW_EVENT_TYPE_CREATED,
+	storage.WindowDeleted:  protov1.WindowEventType_WINDOW_EVENT_TYPE_DELETED,
+	storage.WindowRenamed:  protov1.WindowEventType_WINDOW_EVENT_TYPE_RENAMED,
+	storage.WindowMoved:    protov1.WindowEventType_WINDOW_EVENT_TYPE_MOVED,
+	storage.WindowActivity: protov1.WindowEventType_WINDOW_EVENT_TYPE_ACTIVITY,
+}
+
+var paneEventTypes = map[storage.PaneEventType]protov1.PaneEventType{
+	storage.PaneCreated: protov1.PaneEventType_PANE_EVENT_TYPE_CREATED,
+	storage.PaneDeleted: protov1.PaneEventType_PANE_EVENT_TYPE_DELETED,
+	storage.PaneResized: protov1.PaneEventType_PANE_EVENT_TYPE_RESIZED,
+	storage.PaneMoved:   protov1.PaneEventType_PANE_EVENT_TYPE_MOVED,
+}
+
+func SessionEventToProto(event storage.SessionEvent) *protov1.SessionEvent {
+	return &protov1.SessionEvent{
+		Type:    sessionEventTypes[event.Type],
+		Session: SessionToProto(event.Session),
+	}
+}
+
+// WindowEventToProto numbers the window from baseIndex like WindowToProto.
+func WindowEventToProto(event storage.WindowEvent, baseIndex int) *protov1.WindowEvent {
+	return &protov1.WindowEvent{
+		Type:   windowEventTypes[event.Type],
+		Window: WindowToProto(event.Window, baseIndex),
+	}
+}
+
+func PaneEventToProto(event storage.PaneEvent) *protov1.PaneEvent {
+	return &protov1.PaneEvent{
+		Type: paneEventTypes[event.Type],
+		Pane: PaneToProto(event.Pane),
+	}
+}
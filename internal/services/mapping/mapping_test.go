@@ -0,0 +1,102 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cchirag/ira/internal/enums"
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/google/uuid"
+)
+
+var (
+	createdAt = time.Date(2024, 3, 1, 9, 30, 0, 123456789, time.UTC)
+	updatedAt = createdAt.Add(time.Minute)
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	session := storage.SessionEntry{
+		SchemaVersion: storage.CurrentSchemaVersion,
+		ID:            uuid.New(),
+		Name:          "mapped",
+		Status:        enums.Terminated,
+		CreatedAt:     createdAt,
+		UpdatedAt:     updatedAt,
+	}
+
+	message := SessionToProto(session)
+	if message.Status != "TERMINATED" {
+		t.Fatalf("expected status name, got %q", message.Status)
+	}
+
+	back, err := SessionFromProto(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, session) {
+		t.Fatalf("round trip changed session:\n got %+v\nwant %+v", back, session)
+	}
+}
+
+func TestWindowRoundTrip(t *testing.T) {
+	window := storage.WindowEntry{
+		SchemaVersion: storage.CurrentSchemaVersion,
+		ID:            uuid.New(),
+		Name:          "Window-abc",
+		Index:         3,
+		SessionID:     uuid.New(),
+		HasActivity:   true,
+		Cwd:           "/srv",
+		CreatedAt:     createdAt,
+		UpdatedAt:     updatedAt,
+	}
+
+	for _, base := range []int{0, 1} {
+		message := WindowToProto(window, base)
+		if message.GetIndex() != int32(window.Index+base) {
+			t.Fatalf("base %d: expected display index %d, got %d", base, window.Index+base, message.GetIndex())
+		}
+
+		back, err := WindowFromProto(message, base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back, window) {
+			t.Fatalf("base %d: round trip changed window:\n got %+v\nwant %+v", base, back, window)
+		}
+	}
+}
+
+func TestPaneRoundTrip(t *testing.T) {
+	pane := storage.PaneEntry{
+		SchemaVersion: storage.CurrentSchemaVersion,
+		ID:            uuid.New(),
+		SsessionID:    uuid.New(),
+		WindowID:      uuid.New(),
+		Width:         80,
+		Height:        24,
+		X:             40,
+		Y:             12,
+		Cwd:           "/tmp",
+		CreatedAt:     createdAt,
+		UpdatedAt:     updatedAt,
+	}
+
+	back, err := PaneFromProto(PaneToProto(pane))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, pane) {
+		t.Fatalf("round trip changed pane:\n got %+v\nwant %+v", back, pane)
+	}
+}
+
+func TestFromProtoRejectsBadIDs(t *testing.T) {
+	message := PaneToProto(storage.PaneEntry{ID: uuid.New()})
+	message.WindowId = "not-a-uuid"
+
+	if _, err := PaneFromProto(message); err == nil {
+		t.Fatal("expected an invalid window id to be rejected")
+	}
+}
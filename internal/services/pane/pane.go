@@ -0,0 +1,212 @@
+package pane
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/cchirag/ira/internal/services/mapping"
+	"github.com/cchirag/ira/internal/services/rpcerr"
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/cchirag/ira/internal/terminal"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const eventBuffer = 64
+
+// MaxGeometry bounds every pane coordinate and dimension a client may send.
+// Nothing real comes close; larger values are a bug or abuse.
+const MaxGeometry = 10000
+
+var errReadOnly = status.Error(codes.FailedPrecondition, "daemon is read-only")
+
+type Service struct {
+	protov1.UnimplementedPaneServiceServer
+	Db *bbolt.DB
+	// ReadOnly rejects every RPC that would write to the DB.
+	ReadOnly bool
+	// Terminals holds the I/O of running panes for Attach. Without it every
+	// attach fails as not running.
+	Terminals *terminal.Registry
+}
+
+func (s *Service) CreatePane(ctx context.Context, request *protov1.CreatePaneRequest) (*protov1.CreatePaneResponse, error) {
+	if s.ReadOnly {
+		return nil, errReadOnly
+	}
+
+	sessionId, err := uuid.Parse(request.GetSessionId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid session id")
+	}
+	windowId, err := uuid.Parse(request.GetWindowId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid window id")
+	}
+
+	for _, field := range []struct {
+		name  string
+		value int32
+	}{
+		{"width", request.GetWidth()},
+		{"height", request.GetHeight()},
+		{"x", request.GetX()},
+		{"y", request.GetY()},
+	} {
+		if field.value < 0 || field.value > MaxGeometry {
+			return nil, status.Errorf(codes.InvalidArgument, "%s %d out of range 0..%d", field.name, field.value, MaxGeometry)
+		}
+	}
+
+	var pane storage.PaneEntry
+
+	if err := s.Db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		pane, err = storage.NewPane(tx, sessionId, windowId, request.GetWidth(), request.GetHeight(), request.GetX(), request.GetY(), request.GetCwd())
+		return err
+	}); err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.CreatePaneResponse{
+		Pane: mapping.PaneToProto(pane),
+	}, nil
+}
+
+func (s *Service) WatchPanes(request *protov1.WatchPanesRequest, stream grpc.ServerStreamingServer[protov1.PaneEvent]) error {
+	sessionId, err := uuid.Parse(request.GetSessionId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	var windowId uuid.UUID
+	if request.GetWindowId() != "" {
+		if windowId, err = uuid.Parse(request.GetWindowId()); err != nil {
+			return status.Error(codes.InvalidArgument, "invalid window id")
+		}
+	}
+
+	if err := s.Db.View(func(tx *bbolt.Tx) error {
+		if windowId != uuid.Nil {
+			_, err := storage.GetWindow(tx, sessionId, windowId)
+			return err
+		}
+
+		_, err := storage.GetSession(tx, sessionId)
+		return err
+	}); err != nil {
+		return rpcerr.FromStorage(err)
+	}
+
+	events, cancel := storage.PaneEvents.Subscribe(eventBuffer)
+	defer cancel()
+
+	// Headers tell the client the subscription is live.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if event.Pane.SsessionID != sessionId {
+				continue
+			}
+			if windowId != uuid.Nil && event.Pane.WindowID != windowId {
+				continue
+			}
+
+			if err := stream.Send(mapping.PaneEventToProto(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Service) Attach(stream grpc.BidiStreamingServer[protov1.AttachMessage, protov1.OutputFrame]) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	selector := first.GetSelect()
+	if selector == nil {
+		return status.Error(codes.InvalidArgument, "first attach message must select a pane")
+	}
+
+	sessionId, err := uuid.Parse(selector.GetSessionId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid session id")
+	}
+	windowId, err := uuid.Parse(selector.GetWindowId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid window id")
+	}
+	paneId, err := uuid.Parse(selector.GetPaneId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid pane id")
+	}
+
+	if err := s.Db.View(func(tx *bbolt.Tx) error {
+		_, err := storage.GetPane(tx, sessionId, windowId, paneId)
+		return err
+	}); err != nil {
+		return rpcerr.FromStorage(err)
+	}
+
+	var running *terminal.Pane
+	if s.Terminals != nil {
+		running, _ = s.Terminals.Get(paneId)
+	}
+	if running == nil {
+		return status.Error(codes.FailedPrecondition, "pane is not running")
+	}
+
+	output, cancel := running.Output.Subscribe(eventBuffer)
+	defer cancel()
+
+	// Headers tell the client output is being forwarded.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	inputErr := make(chan error, 1)
+	go func() {
+		for {
+			message, err := stream.Recv()
+			if err != nil {
+				inputErr <- err
+				return
+			}
+
+			if _, err := running.Input.Write(message.GetInput()); err != nil {
+				inputErr <- status.Errorf(codes.Unavailable, "write input: %v", err)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case err := <-inputErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case data := <-output:
+			if err := stream.Send(&protov1.OutputFrame{Data: data}); err != nil {
+				return err
+			}
+		}
+	}
+}
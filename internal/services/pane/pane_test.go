@@ -0,0 +1,331 @@
+package pane
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/cchirag/ira/internal/services/rpcerr"
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/cchirag/ira/internal/terminal"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) (protov1.PaneServiceClient, *bbolt.DB) {
+	t.Helper()
+
+	client, db, _ := newTestClientWithTerminals(t)
+	return client, db
+}
+
+func newTestClientWithTerminals(t *testing.T) (protov1.PaneServiceClient, *bbolt.DB, *terminal.Registry) {
+	t.Helper()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terminals := terminal.NewRegistry()
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	protov1.RegisterPaneServiceServer(server, &Service{Db: db, Terminals: terminals})
+
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+		db.Close()
+	})
+
+	return protov1.NewPaneServiceClient(conn), db, terminals
+}
+
+func TestWatchPanes(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	var window, other storage.WindowEntry
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "watch"); err != nil {
+			return err
+		}
+		if window, err = storage.NewWindow(tx, session.ID); err != nil {
+			return err
+		}
+		other, err = storage.NewWindow(tx, session.ID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchPanes(ctx, &protov1.WatchPanesRequest{
+		SessionId: session.ID.String(),
+		WindowId:  window.ID.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		// Panes in other windows are filtered out.
+		if _, err := storage.NewPane(tx, session.ID, other.ID, 80, 24, 0, 0, ""); err != nil {
+			return err
+		}
+
+		pane, err := storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "")
+		if err != nil {
+			return err
+		}
+
+		if err := storage.UpdatePaneSize(tx, session.ID, window.ID, pane.ID, 120, 40); err != nil {
+			return err
+		}
+
+		return storage.DeletePane(tx, session.ID, window.ID, pane.ID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []protov1.PaneEventType{
+		protov1.PaneEventType_PANE_EVENT_TYPE_CREATED,
+		protov1.PaneEventType_PANE_EVENT_TYPE_RESIZED,
+		protov1.PaneEventType_PANE_EVENT_TYPE_DELETED,
+	}
+
+	for _, want := range expected {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != want {
+			t.Fatalf("expected %s, got %s", want, event.Type)
+		}
+		if event.Pane.WindowId != window.ID.String() {
+			t.Fatalf("unexpected event for window %s", event.Pane.WindowId)
+		}
+	}
+}
+
+func TestWatchPanesMissingWindow(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "missing"); err != nil {
+			return err
+		}
+		_, err = storage.NewWindow(tx, session.ID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.WatchPanes(context.Background(), &protov1.WatchPanesRequest{
+		SessionId: session.ID.String(),
+		WindowId:  uuid.NewString(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = stream.Recv()
+	st := status.Convert(err)
+	if st.Code() != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	var reason string
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			reason = info.Reason
+		}
+	}
+	if reason != rpcerr.ReasonWindowNotFound {
+		t.Fatalf("expected reason %s, got %q", rpcerr.ReasonWindowNotFound, reason)
+	}
+}
+
+func TestAttachEchoesInput(t *testing.T) {
+	client, db, terminals := newTestClientWithTerminals(t)
+
+	var session storage.SessionEntry
+	var window storage.WindowEntry
+	var pane storage.PaneEntry
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "attached"); err != nil {
+			return err
+		}
+		if window, err = storage.NewWindow(tx, session.ID); err != nil {
+			return err
+		}
+		pane, err = storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Input written straight to the output buffer makes an echoing pane.
+	echo := terminal.NewBuffer()
+	terminals.Register(pane.ID, &terminal.Pane{Input: echo, Output: echo})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Attach(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stream.Send(&protov1.AttachMessage{
+		Message: &protov1.AttachMessage_Select{Select: &protov1.PaneSelector{
+			SessionId: session.ID.String(),
+			WindowId:  window.ID.String(),
+			PaneId:    pane.ID.String(),
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := stream.Send(&protov1.AttachMessage{
+		Message: &protov1.AttachMessage_Input{Input: []byte("echo hi\n")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(frame.Data) != "echo hi\n" {
+		t.Fatalf("expected input echoed back, got %q", frame.Data)
+	}
+}
+
+func TestAttachPaneNotRunning(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	var window storage.WindowEntry
+	var pane storage.PaneEntry
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "idle"); err != nil {
+			return err
+		}
+		if window, err = storage.NewWindow(tx, session.ID); err != nil {
+			return err
+		}
+		pane, err = storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Attach(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&protov1.AttachMessage{
+		Message: &protov1.AttachMessage_Select{Select: &protov1.PaneSelector{
+			SessionId: session.ID.String(),
+			WindowId:  window.ID.String(),
+			PaneId:    pane.ID.String(),
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stream.Recv(); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestCreatePaneGeometryOutOfRange(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	var window storage.WindowEntry
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "geometry"); err != nil {
+			return err
+		}
+		window, err = storage.NewWindow(tx, session.ID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	request := &protov1.CreatePaneRequest{
+		SessionId: session.ID.String(),
+		WindowId:  window.ID.String(),
+		Width:     80,
+		Height:    24,
+		X:         1 << 30,
+	}
+	if _, err := client.CreatePane(context.Background(), request); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+
+	request.X = 0
+	response, err := client.CreatePane(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetPane().GetWidth() != 80 {
+		t.Fatalf("expected width 80, got %d", response.GetPane().GetWidth())
+	}
+}
+
+func TestCreatePaneReadOnly(t *testing.T) {
+	s := &Service{ReadOnly: true}
+
+	_, err := s.CreatePane(context.Background(), &protov1.CreatePaneRequest{
+		SessionId: uuid.NewString(),
+		WindowId:  uuid.NewString(),
+		Width:     80,
+		Height:    24,
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
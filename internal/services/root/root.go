@@ -2,14 +2,35 @@ package root
 
 import (
 	"context"
+	"errors"
+	"time"
 
+	"github.com/cchirag/ira/internal/services/mapping"
+	"github.com/cchirag/ira/internal/services/rpcerr"
+	"github.com/cchirag/ira/internal/storage"
 	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/google/uuid"
 	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+const eventBuffer = 64
+
+var errReadOnly = status.Error(codes.FailedPrecondition, "daemon is read-only")
+
 type Service struct {
 	protov1.UnimplementedRootServiceServer
-	Db *bbolt.DB
+	Db        *bbolt.DB
+	StartedAt time.Time
+	// ReadOnly and AllowReset gate Reset and a replacing Import; they only
+	// run when the DB is writable and resets were explicitly allowed.
+	ReadOnly   bool
+	AllowReset bool
 }
 
 func (s *Service) Ping(ctx context.Context, request *protov1.PingRequest) (*protov1.PingResponse, error) {
@@ -22,3 +43,184 @@ func (s *Service) Ping(ctx context.Context, request *protov1.PingRequest) (*prot
 		Db: db,
 	}, nil
 }
+
+func (s *Service) Stats(ctx context.Context, request *protov1.StatsRequest) (*protov1.StatsResponse, error) {
+	if s.Db == nil {
+		return nil, status.Error(codes.Unavailable, "db not open")
+	}
+
+	var stats []storage.BucketStats
+
+	if err := s.Db.View(func(tx *bbolt.Tx) error {
+		var err error
+		stats, err = storage.GetBucketStats(tx)
+		return err
+	}); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	buckets := make([]*protov1.BucketStats, 0, len(stats))
+	for _, bucket := range stats {
+		buckets = append(buckets, &protov1.BucketStats{
+			Name:      bucket.Name,
+			KeyCount:  int64(bucket.KeyCount),
+			Buckets:   int64(bucket.Buckets),
+			SizeBytes: int64(bucket.SizeBytes),
+		})
+	}
+
+	return &protov1.StatsResponse{
+		Buckets: buckets,
+	}, nil
+}
+
+func (s *Service) Info(ctx context.Context, request *protov1.InfoRequest) (*protov1.InfoResponse, error) {
+	var path string
+	if s.Db != nil {
+		path = s.Db.Path()
+	}
+
+	return &protov1.InfoResponse{
+		DbPath:    path,
+		StartedAt: timestamppb.New(s.StartedAt),
+		Uptime:    durationpb.New(time.Since(s.StartedAt)),
+	}, nil
+}
+
+func (s *Service) Reset(ctx context.Context, request *protov1.ResetRequest) (*protov1.ResetResponse, error) {
+	if s.ReadOnly {
+		return nil, errReadOnly
+	}
+	if !s.AllowReset {
+		return nil, status.Error(codes.PermissionDenied, "reset is disabled; start the daemon with --allow-reset")
+	}
+	if s.Db == nil {
+		return nil, status.Error(codes.Unavailable, "db not open")
+	}
+
+	if err := s.Db.Update(storage.ResetDatabase); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &protov1.ResetResponse{}, nil
+}
+
+func (s *Service) Export(ctx context.Context, request *protov1.ExportRequest) (*protov1.ExportResponse, error) {
+	if s.Db == nil {
+		return nil, status.Error(codes.Unavailable, "db not open")
+	}
+
+	var snapshot []byte
+
+	if err := s.Db.View(func(tx *bbolt.Tx) error {
+		var err error
+		snapshot, err = storage.ExportAll(tx)
+		return err
+	}); err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.ExportResponse{
+		Snapshot: snapshot,
+	}, nil
+}
+
+func (s *Service) Import(ctx context.Context, request *protov1.ImportRequest) (*protov1.ImportResponse, error) {
+	if s.ReadOnly {
+		return nil, errReadOnly
+	}
+
+	var mode storage.ImportMode
+	switch request.GetMode() {
+	case protov1.ImportMode_IMPORT_MODE_UNSPECIFIED, protov1.ImportMode_IMPORT_MODE_MERGE:
+		mode = storage.Merge
+	case protov1.ImportMode_IMPORT_MODE_REPLACE:
+		if !s.AllowReset {
+			return nil, status.Error(codes.PermissionDenied, "replace is disabled; start the daemon with --allow-reset")
+		}
+		mode = storage.Replace
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown import mode %d", request.GetMode())
+	}
+
+	if s.Db == nil {
+		return nil, status.Error(codes.Unavailable, "db not open")
+	}
+
+	if err := s.Db.Update(func(tx *bbolt.Tx) error {
+		return storage.ImportAll(tx, request.GetSnapshot(), mode)
+	}); err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.ImportResponse{}, nil
+}
+
+// WatchAll forwards every session, window and pane event, of every session,
+// until the client goes away.
+func (s *Service) WatchAll(request *protov1.WatchAllRequest, stream grpc.ServerStreamingServer[protov1.Event]) error {
+	if s.Db == nil {
+		return status.Error(codes.Unavailable, "db not open")
+	}
+
+	sessions, cancelSessions := storage.SessionEvents.Subscribe(eventBuffer)
+	defer cancelSessions()
+	windows, cancelWindows := storage.WindowEvents.Subscribe(eventBuffer)
+	defer cancelWindows()
+	panes, cancelPanes := storage.PaneEvents.Subscribe(eventBuffer)
+	defer cancelPanes()
+
+	// Headers tell the client the subscription is live.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		var event *protov1.Event
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-sessions:
+			event = &protov1.Event{
+				EntityType: protov1.EntityType_ENTITY_TYPE_SESSION,
+				Payload:    &protov1.Event_Session{Session: mapping.SessionEventToProto(e)},
+			}
+		case e := <-windows:
+			baseIndex, err := s.baseIndex(e.Window.SessionID)
+			if err != nil {
+				return rpcerr.FromStorage(err)
+			}
+			event = &protov1.Event{
+				EntityType: protov1.EntityType_ENTITY_TYPE_WINDOW,
+				Payload:    &protov1.Event_Window{Window: mapping.WindowEventToProto(e, baseIndex)},
+			}
+		case e := <-panes:
+			event = &protov1.Event{
+				EntityType: protov1.EntityType_ENTITY_TYPE_PANE,
+				Payload:    &protov1.Event_Pane{Pane: mapping.PaneEventToProto(e)},
+			}
+		}
+
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+// baseIndex reads the session's base-index for mapping window numbers. As in
+// WatchWindows, a deleted session maps with 0.
+func (s *Service) baseIndex(sessionId uuid.UUID) (int, error) {
+	var baseIndex int
+
+	err := s.Db.View(func(tx *bbolt.Tx) error {
+		var err error
+		baseIndex, err = storage.GetBaseIndex(tx, sessionId)
+		if errors.Is(err, storage.ErrSessionNotFound) || errors.Is(err, storage.ErrSessionBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+
+	return baseIndex, err
+}
@@ -0,0 +1,308 @@
+package root
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/cchirag/ira/internal/storage/storagetest"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func openTestDB(t *testing.T) *bbolt.DB {
+	t.Helper()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	return db
+}
+
+func TestStats(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		session, err := storage.NewSession(tx, "stats")
+		if err != nil {
+			return err
+		}
+
+		window, err := storage.NewWindow(tx, session.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "/tmp")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Service{Db: db}
+
+	response, err := s.Stats(context.Background(), &protov1.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Buckets) != 3 {
+		t.Fatalf("expected 3 buckets, got %d", len(response.Buckets))
+	}
+
+	for _, bucket := range response.Buckets {
+		if bucket.KeyCount == 0 {
+			t.Fatalf("expected keys in bucket %s", bucket.Name)
+		}
+		if bucket.SizeBytes == 0 {
+			t.Fatalf("expected non-zero size for bucket %s", bucket.Name)
+		}
+	}
+}
+
+func TestInfo(t *testing.T) {
+	db := openTestDB(t)
+	s := &Service{Db: db, StartedAt: time.Now()}
+
+	first, err := s.Info(context.Background(), &protov1.InfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.DbPath == "" {
+		t.Fatal("expected db path to be set")
+	}
+	if first.DbPath != db.Path() {
+		t.Fatalf("expected db path %s, got %s", db.Path(), first.DbPath)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	second, err := s.Info(context.Background(), &protov1.InfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Uptime.AsDuration() <= first.Uptime.AsDuration() {
+		t.Fatalf("expected uptime to increase: %s then %s", first.Uptime.AsDuration(), second.Uptime.AsDuration())
+	}
+}
+
+func TestReset(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		session, err := storage.NewSession(tx, "reset")
+		if err != nil {
+			return err
+		}
+
+		window, err := storage.NewWindow(tx, session.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "/tmp")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (&Service{Db: db}).Reset(context.Background(), &protov1.ResetRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied without --allow-reset, got %v", err)
+	}
+	if _, err := (&Service{Db: db, AllowReset: true, ReadOnly: true}).Reset(context.Background(), &protov1.ResetRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition on a read-only daemon, got %v", err)
+	}
+
+	s := &Service{Db: db, AllowReset: true}
+	if _, err := s.Reset(context.Background(), &protov1.ResetRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := s.Stats(context.Background(), &protov1.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range response.Buckets {
+		if bucket.KeyCount != 0 {
+			t.Fatalf("expected bucket %s to be empty after reset, got %d keys", bucket.Name, bucket.KeyCount)
+		}
+	}
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		if sessions, err := storage.GetSessions(tx); err != nil || len(sessions) != 0 {
+			t.Fatalf("expected no sessions after reset, got %d, %v", len(sessions), err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchAllMultiplexesEvents(t *testing.T) {
+	db := openTestDB(t)
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	protov1.RegisterRootServiceServer(server, &Service{Db: db})
+
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := protov1.NewRootServiceClient(conn).WatchAll(ctx, &protov1.WatchAllRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	var session storage.SessionEntry
+	var pane storage.PaneEntry
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "watched"); err != nil {
+			return err
+		}
+
+		window, err := storage.NewWindow(tx, session.ID)
+		if err != nil {
+			return err
+		}
+
+		pane, err = storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "/tmp")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The session, window and pane events arrive on one stream, in any order.
+	seen := map[protov1.EntityType]*protov1.Event{}
+	for range 3 {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[event.GetEntityType()] = event
+	}
+
+	if got := seen[protov1.EntityType_ENTITY_TYPE_SESSION].GetSession(); got.GetType() != protov1.SessionEventType_SESSION_EVENT_TYPE_CREATED ||
+		got.GetSession().GetId() != session.ID.String() {
+		t.Fatalf("expected a session created event for %s, got %v", session.ID, got)
+	}
+	if got := seen[protov1.EntityType_ENTITY_TYPE_PANE].GetPane(); got.GetType() != protov1.PaneEventType_PANE_EVENT_TYPE_CREATED ||
+		got.GetPane().GetId() != pane.ID.String() {
+		t.Fatalf("expected a pane created event for %s, got %v", pane.ID, got)
+	}
+	if seen[protov1.EntityType_ENTITY_TYPE_WINDOW].GetWindow() == nil {
+		t.Fatalf("expected a window event, got %v", seen)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	source := openTestDB(t)
+	storagetest.SeedSession(t, source, "exported", 2, 2)
+
+	response, err := (&Service{Db: source}).Export(context.Background(), &protov1.ExportRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destination := openTestDB(t)
+	s := &Service{Db: destination}
+
+	replace := &protov1.ImportRequest{Snapshot: response.GetSnapshot(), Mode: protov1.ImportMode_IMPORT_MODE_REPLACE}
+	if _, err := s.Import(context.Background(), replace); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for a replace without --allow-reset, got %v", err)
+	}
+
+	readOnly := &Service{Db: destination, ReadOnly: true, AllowReset: true}
+	if _, err := readOnly.Import(context.Background(), &protov1.ImportRequest{Snapshot: response.GetSnapshot()}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition on a read-only daemon, got %v", err)
+	}
+
+	if _, err := s.Import(context.Background(), &protov1.ImportRequest{Snapshot: []byte("not json")}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a malformed snapshot, got %v", err)
+	}
+
+	if _, err := s.Import(context.Background(), &protov1.ImportRequest{Snapshot: response.GetSnapshot()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := destination.View(func(tx *bbolt.Tx) error {
+		session, err := storage.GetSessionByName(tx, "exported")
+		if err != nil {
+			return err
+		}
+
+		panes, err := storage.GetAllPanes(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		if len(panes) != 4 {
+			t.Fatalf("expected 4 imported panes, got %d", len(panes))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportCorruptEntryIsDataLoss(t *testing.T) {
+	source := openTestDB(t)
+	seeded := storagetest.SeedSession(t, source, "exported", 1, 1)
+
+	response, err := (&Service{Db: source}).Export(context.Background(), &protov1.ExportRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The destination holds a damaged entry under the snapshot session's ID.
+	destination := openTestDB(t)
+	if err := destination.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(storage.SessionBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(seeded.SessionID.String()), []byte("garbage"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = (&Service{Db: destination}).Import(context.Background(), &protov1.ImportRequest{Snapshot: response.GetSnapshot()})
+	if status.Code(err) != codes.DataLoss {
+		t.Fatalf("expected DataLoss, got %v", err)
+	}
+}
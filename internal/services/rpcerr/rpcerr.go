@@ -0,0 +1,201 @@
+package rpcerr
+
+// Package rpcerr maps storage errors to gRPC statuses shared by every service.
+//
+// NotFound statuses carry an errdetails.ErrorInfo with Domain "ira" and a
+// Reason naming the missing entity, so clients can tell which one was absent.
+// The precondition and argument errors listed in reasons carry one too.
+// Every status built from a storage error other than a context error also
+// carries a protov1.ErrorDetail with its application ErrorCode.
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cchirag/ira/internal/storage"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const Domain = "ira"
+
+const (
+	ReasonSessionNotFound = "SESSION_NOT_FOUND"
+	ReasonWindowNotFound  = "WINDOW_NOT_FOUND"
+	ReasonPaneNotFound    = "PANE_NOT_FOUND"
+	ReasonLayoutNotFound  = "LAYOUT_NOT_FOUND"
+
+	ReasonWindowNotEmpty            = "WINDOW_NOT_EMPTY"
+	ReasonReadOnly                  = "READ_ONLY"
+	ReasonNameGenerationFailed      = "NAME_GENERATION_FAILED"
+	ReasonInvalidSessionStatus      = "INVALID_SESSION_STATUS"
+	ReasonSchemaTooNew              = "SCHEMA_TOO_NEW"
+	ReasonInvalidWindowNameAlphabet = "INVALID_WINDOW_NAME_ALPHABET"
+)
+
+var notFound = []struct {
+	reason string
+	errs   []error
+}{
+	{ReasonSessionNotFound, []error{storage.ErrSessionNotFound, storage.ErrSessionBucketNotFound}},
+	{ReasonWindowNotFound, []error{storage.ErrWindowNotFound, storage.ErrWindowBucketNotFound, storage.ErrWindowSessionBucketNotFound}},
+	{ReasonPaneNotFound, []error{storage.ErrPaneNotFound, storage.ErrPaneBucketNotFound, storage.ErrPaneWindowBucketNotFound}},
+	{ReasonLayoutNotFound, []error{storage.ErrLayoutNotFound, storage.ErrLayoutBucketNotFound}},
+}
+
+// reasons maps storage errors outside notFound that carry an ErrorInfo to
+// their status code and Reason.
+var reasons = []struct {
+	code   codes.Code
+	reason string
+	errs   []error
+}{
+	{codes.FailedPrecondition, ReasonWindowNotEmpty, []error{storage.ErrWindowNotEmpty}},
+	{codes.FailedPrecondition, ReasonReadOnly, []error{storage.ErrReadOnlyTx}},
+	{codes.FailedPrecondition, ReasonNameGenerationFailed, []error{storage.ErrNameGenerationFailed}},
+	{codes.FailedPrecondition, ReasonSchemaTooNew, []error{storage.ErrSchemaTooNew}},
+	{codes.FailedPrecondition, ReasonInvalidWindowNameAlphabet, []error{storage.ErrInvalidWindowNameAlphabet}},
+	{codes.InvalidArgument, ReasonInvalidSessionStatus, []error{storage.ErrInvalidSessionStatus}},
+}
+
+func isAny(err error, targets ...error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+var errorCodes = []struct {
+	code protov1.ErrorCode
+	errs []error
+}{
+	{protov1.ErrorCode_IRA_SESSION_NOT_FOUND, []error{storage.ErrSessionNotFound, storage.ErrSessionBucketNotFound}},
+	{protov1.ErrorCode_IRA_WINDOW_NOT_FOUND, []error{storage.ErrWindowNotFound, storage.ErrWindowBucketNotFound, storage.ErrWindowSessionBucketNotFound}},
+	{protov1.ErrorCode_IRA_PANE_NOT_FOUND, []error{storage.ErrPaneNotFound, storage.ErrPaneBucketNotFound, storage.ErrPaneWindowBucketNotFound}},
+	{protov1.ErrorCode_IRA_LAYOUT_NOT_FOUND, []error{storage.ErrLayoutNotFound, storage.ErrLayoutBucketNotFound}},
+	{protov1.ErrorCode_IRA_NAME_TAKEN, []error{storage.ErrSessionAlreadyExists}},
+	{protov1.ErrorCode_IRA_ID_TAKEN, []error{storage.ErrWindowAlreadyExists, storage.ErrPaneAlreadyExists}},
+	{protov1.ErrorCode_IRA_INVALID_NAME, []error{storage.ErrEmptySessionName, storage.ErrInvalidSessionName, storage.ErrEmptyWindowName}},
+	{protov1.ErrorCode_IRA_INVALID_ID, []error{storage.ErrInvalidPaneID}},
+	{protov1.ErrorCode_IRA_INVALID_CWD, []error{storage.ErrRelativeCwd, storage.ErrCwdNotFound}},
+	{protov1.ErrorCode_IRA_INVALID_LABEL_KEY, []error{storage.ErrEmptyLabelKey, storage.ErrInvalidLabelKey}},
+	{protov1.ErrorCode_IRA_INVALID_OPTION_KEY, []error{storage.ErrInvalidOptionKey}},
+	{protov1.ErrorCode_IRA_INVALID_OPTION_VALUE, []error{storage.ErrInvalidOptionValue}},
+	{protov1.ErrorCode_IRA_INVALID_TEXT, []error{storage.ErrInvalidText}},
+	{protov1.ErrorCode_IRA_UNSUPPORTED_EXPORT_VERSION, []error{storage.ErrUnsupportedExportVersion, storage.ErrUnsupportedSnapshotVersion}},
+	{protov1.ErrorCode_IRA_LIMIT_REACHED, []error{storage.ErrWindowLimitReached, storage.ErrPaneLimitReached}},
+	{protov1.ErrorCode_IRA_WINDOW_TOO_SMALL, []error{storage.ErrWindowTooSmall}},
+	{protov1.ErrorCode_IRA_SESSION_TERMINATED, []error{storage.ErrSessionTerminated}},
+	{protov1.ErrorCode_IRA_CORRUPT_ENTRY, []error{storage.ErrCorruptEntry}},
+	{protov1.ErrorCode_IRA_INDEX_OUT_OF_RANGE, []error{storage.ErrIndexOutOfRange}},
+	{protov1.ErrorCode_IRA_ENTRY_TOO_LARGE, []error{storage.ErrEntryTooLarge}},
+	{protov1.ErrorCode_IRA_INVALID_SIZE, []error{storage.ErrInvalidDefaultSize}},
+	{protov1.ErrorCode_IRA_INVALID_SNAPSHOT, []error{storage.ErrInvalidSnapshot}},
+	{protov1.ErrorCode_IRA_INVALID_IMPORT_MODE, []error{storage.ErrInvalidImportMode}},
+	{protov1.ErrorCode_IRA_WINDOW_NOT_EMPTY, []error{storage.ErrWindowNotEmpty}},
+	{protov1.ErrorCode_IRA_READ_ONLY, []error{storage.ErrReadOnlyTx}},
+	{protov1.ErrorCode_IRA_NAME_GENERATION_FAILED, []error{storage.ErrNameGenerationFailed}},
+	{protov1.ErrorCode_IRA_INVALID_SESSION_STATUS, []error{storage.ErrInvalidSessionStatus}},
+	{protov1.ErrorCode_IRA_SCHEMA_TOO_NEW, []error{storage.ErrSchemaTooNew}},
+	{protov1.ErrorCode_IRA_INVALID_NAME_ALPHABET, []error{storage.ErrInvalidWindowNameAlphabet}},
+}
+
+// ErrorCode returns the application error code for a storage error. Errors
+// without a dedicated code are IRA_INTERNAL; context errors are unspecified.
+func ErrorCode(err error) protov1.ErrorCode {
+	for _, entry := range errorCodes {
+		if isAny(err, entry.errs...) {
+			return entry.code
+		}
+	}
+
+	if isAny(err, context.Canceled, context.DeadlineExceeded) {
+		return protov1.ErrorCode_IRA_ERROR_CODE_UNSPECIFIED
+	}
+
+	return protov1.ErrorCode_IRA_INTERNAL
+}
+
+// FromStorage converts an error returned by the storage package into a gRPC
+// status error.
+func FromStorage(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	st := fromStorage(err)
+
+	if code := ErrorCode(err); code != protov1.ErrorCode_IRA_ERROR_CODE_UNSPECIFIED {
+		if detailed, detailErr := st.WithDetails(&protov1.ErrorDetail{Code: code}); detailErr == nil {
+			st = detailed
+		}
+	}
+
+	return st.Err()
+}
+
+func fromStorage(err error) *status.Status {
+	for _, entry := range notFound {
+		if isAny(err, entry.errs...) {
+			return withReason(codes.NotFound, err.Error(), entry.reason)
+		}
+	}
+
+	for _, entry := range reasons {
+		if isAny(err, entry.errs...) {
+			return withReason(entry.code, err.Error(), entry.reason)
+		}
+	}
+
+	switch {
+	case isAny(err, context.Canceled, context.DeadlineExceeded):
+		return status.FromContextError(err)
+	case isAny(err, storage.ErrSessionAlreadyExists, storage.ErrWindowAlreadyExists, storage.ErrPaneAlreadyExists):
+		return status.New(codes.AlreadyExists, err.Error())
+	case isAny(err,
+		storage.ErrEmptySessionName,
+		storage.ErrInvalidSessionName,
+		storage.ErrRelativeCwd,
+		storage.ErrCwdNotFound,
+		storage.ErrEmptyLabelKey,
+		storage.ErrInvalidLabelKey,
+		storage.ErrInvalidOptionKey,
+		storage.ErrInvalidOptionValue,
+		storage.ErrInvalidPaneID,
+		storage.ErrInvalidText,
+		storage.ErrEmptyWindowName,
+		storage.ErrUnsupportedExportVersion,
+		storage.ErrUnsupportedSnapshotVersion,
+		storage.ErrInvalidSnapshot,
+		storage.ErrInvalidImportMode,
+		storage.ErrEntryTooLarge,
+		storage.ErrInvalidDefaultSize):
+		return status.New(codes.InvalidArgument, err.Error())
+	case isAny(err, storage.ErrIndexOutOfRange):
+		return status.New(codes.OutOfRange, err.Error())
+	case isAny(err, storage.ErrWindowLimitReached, storage.ErrPaneLimitReached, storage.ErrWindowTooSmall):
+		return status.New(codes.ResourceExhausted, err.Error())
+	case isAny(err, storage.ErrSessionTerminated):
+		return status.New(codes.FailedPrecondition, err.Error())
+	case isAny(err, storage.ErrCorruptEntry):
+		return status.New(codes.DataLoss, err.Error())
+	default:
+		return status.New(codes.Internal, err.Error())
+	}
+}
+
+func withReason(code codes.Code, message, reason string) *status.Status {
+	st, err := status.New(code, message).WithDetails(&errdetails.ErrorInfo{
+		Reason: reason,
+		Domain: Domain,
+	})
+	if err != nil {
+		return status.New(code, message)
+	}
+
+	return st
+}
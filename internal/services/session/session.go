@@ -0,0 +1,277 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/cchirag/ira/internal/enums"
+	"github.com/cchirag/ira/internal/services/mapping"
+	"github.com/cchirag/ira/internal/services/pane"
+	"github.com/cchirag/ira/internal/services/rpcerr"
+	"github.com/cchirag/ira/internal/storage"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errReadOnly = status.Error(codes.FailedPrecondition, "daemon is read-only")
+
+type Service struct {
+	protov1.UnimplementedSessionServiceServer
+	Db *bbolt.DB
+	// Backend serves the single-step RPCs. When nil they run against Db.
+	// CreateSessionFull and Attach always use Db.
+	Backend storage.Backend
+	// ReadOnly rejects every RPC that would write to the DB.
+	ReadOnly bool
+
+	// locks holds the UUIDs of attached sessions. It is process-local and
+	// only coordinates clients of this daemon.
+	locks sync.Map
+}
+
+func (s *Service) backend() storage.Backend {
+	if s.Backend != nil {
+		return s.Backend
+	}
+	return storage.NewStore(s.Db)
+}
+
+// lockSession takes the advisory attach lock for a session. ok is false if
+// another client already holds it.
+func (s *Service) lockSession(id uuid.UUID) (release func(), ok bool) {
+	if _, held := s.locks.LoadOrStore(id, struct{}{}); held {
+		return nil, false
+	}
+
+	return func() { s.locks.Delete(id) }, true
+}
+
+func (s *Service) CreateSession(ctx context.Context, request *protov1.CreateSessionRequest) (*protov1.CreateSessionResponse, error) {
+	if s.ReadOnly {
+		return nil, errReadOnly
+	}
+
+	session, err := s.backend().CreateSession(ctx, request.GetName())
+	if err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.CreateSessionResponse{
+		Session: mapping.SessionToProto(session),
+	}, nil
+}
+
+func (s *Service) CreateSessionFull(ctx context.Context, request *protov1.CreateSessionFullRequest) (*protov1.CreateSessionFullResponse, error) {
+	if s.ReadOnly {
+		return nil, errReadOnly
+	}
+
+	width, height := request.GetWidth(), request.GetHeight()
+	if width < 0 || width > pane.MaxGeometry || height < 0 || height > pane.MaxGeometry {
+		return nil, status.Errorf(codes.InvalidArgument, "pane size %dx%d out of range 0..%d", width, height, pane.MaxGeometry)
+	}
+
+	var session storage.SessionEntry
+	var window storage.WindowEntry
+	var initial storage.PaneEntry
+	var baseIndex int
+
+	if err := s.Db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, request.GetName()); err != nil {
+			return err
+		}
+
+		if request.GetCwd() != "" {
+			if err := storage.UpdateSessionCwd(tx, session.ID, request.GetCwd()); err != nil {
+				return err
+			}
+		}
+
+		if window, initial, err = storage.NewWindowWithPane(tx, session.ID, width, height); err != nil {
+			return err
+		}
+
+		if err := storage.SetActiveWindow(tx, session.ID, window.ID); err != nil {
+			return err
+		}
+		if err := storage.SetActivePane(tx, session.ID, window.ID, initial.ID); err != nil {
+			return err
+		}
+
+		if session, err = storage.GetSession(tx, session.ID); err != nil {
+			return err
+		}
+		if baseIndex, err = storage.GetBaseIndex(tx, session.ID); err != nil {
+			return err
+		}
+		window, err = storage.GetWindow(tx, session.ID, window.ID)
+		return err
+	}); err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.CreateSessionFullResponse{
+		Session: mapping.SessionToProto(session),
+		Window:  mapping.WindowToProto(window, baseIndex),
+		Pane:    mapping.PaneToProto(initial),
+	}, nil
+}
+
+func (s *Service) ListSessions(ctx context.Context, request *protov1.ListSessionsRequest) (*protov1.ListSessionsResponse, error) {
+	sessions, err := s.backend().GetSessions(ctx)
+	if err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	response := &protov1.ListSessionsResponse{
+		Sessions: make([]*protov1.Session, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, mapping.SessionToProto(session))
+	}
+
+	return response, nil
+}
+
+func (s *Service) BatchGetSessions(ctx context.Context, request *protov1.BatchGetSessionsRequest) (*protov1.BatchGetSessionsResponse, error) {
+	ids := make([]uuid.UUID, 0, len(request.GetSessionIds()))
+	for _, raw := range request.GetSessionIds() {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid session id %q", raw)
+		}
+		ids = append(ids, id)
+	}
+
+	sessions, missing, err := s.backend().GetSessionsByIDs(ctx, ids)
+	if err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	response := &protov1.BatchGetSessionsResponse{
+		Sessions:   make([]*protov1.Session, 0, len(sessions)),
+		MissingIds: make([]string, 0, len(missing)),
+	}
+	for _, session := range sessions {
+		response.Sessions = append(response.Sessions, mapping.SessionToProto(session))
+	}
+	for _, id := range missing {
+		response.MissingIds = append(response.MissingIds, id.String())
+	}
+
+	return response, nil
+}
+
+func (s *Service) CompleteNames(ctx context.Context, request *protov1.CompleteNamesRequest) (*protov1.CompleteNamesResponse, error) {
+	names, err := s.backend().GetSessionNames(ctx, request.GetPrefix())
+	if err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.CompleteNamesResponse{
+		Names: names,
+	}, nil
+}
+
+func (s *Service) CheckName(ctx context.Context, request *protov1.CheckNameRequest) (*protov1.CheckNameResponse, error) {
+	available, err := s.backend().IsSessionNameAvailable(ctx, request.GetName())
+	if err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.CheckNameResponse{
+		Available: available,
+	}, nil
+}
+
+func (s *Service) DeleteSession(ctx context.Context, request *protov1.DeleteSessionRequest) (*protov1.DeleteSessionResponse, error) {
+	if s.ReadOnly {
+		return nil, errReadOnly
+	}
+
+	id, err := uuid.Parse(request.GetSessionId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	windows, panes, err := s.backend().DeleteSession(ctx, id)
+	if err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.DeleteSessionResponse{
+		DeletedWindows: int32(windows),
+		DeletedPanes:   int32(panes),
+	}, nil
+}
+
+func (s *Service) Attach(request *protov1.AttachRequest, stream grpc.ServerStreamingServer[protov1.AttachResponse]) error {
+	if s.ReadOnly {
+		return errReadOnly
+	}
+
+	id, err := uuid.Parse(request.GetSessionId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	if err := s.Db.View(func(tx *bbolt.Tx) error {
+		_, err := storage.GetSession(tx, id)
+		return err
+	}); err != nil {
+		return rpcerr.FromStorage(err)
+	}
+
+	release, ok := s.lockSession(id)
+	if !ok {
+		return status.Error(codes.FailedPrecondition, "session busy")
+	}
+	defer release()
+
+	var session storage.SessionEntry
+
+	if err := s.Db.Update(func(tx *bbolt.Tx) error {
+		current, err := storage.GetSession(tx, id)
+		if err != nil {
+			return err
+		}
+
+		// Resetting to Inactive on detach must not revive a terminated session.
+		if current.Status == enums.Terminated {
+			return storage.ErrSessionTerminated
+		}
+
+		if err := storage.UpdateSessionStatus(tx, id, enums.Active); err != nil {
+			return err
+		}
+
+		session, err = storage.GetSession(tx, id)
+		return err
+	}); err != nil {
+		return rpcerr.FromStorage(err)
+	}
+
+	defer func() {
+		if err := s.Db.Update(func(tx *bbolt.Tx) error {
+			return storage.UpdateSessionStatus(tx, id, enums.Inactive)
+		}); err != nil {
+			slog.Warn("reset session status", "session", id, "err", err)
+		}
+	}()
+
+	if err := stream.Send(&protov1.AttachResponse{
+		Session: mapping.SessionToProto(session),
+	}); err != nil {
+		return err
+	}
+
+	<-stream.Context().Done()
+
+	return nil
+}
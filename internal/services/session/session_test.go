@@ -0,0 +1,517 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cchirag/ira/internal/enums"
+	"github.com/cchirag/ira/internal/services/rpcerr"
+	"github.com/cchirag/ira/internal/storage"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) (protov1.SessionServiceClient, *bbolt.DB) {
+	t.Helper()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	protov1.RegisterSessionServiceServer(server, &Service{Db: db})
+
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+		db.Close()
+	})
+
+	return protov1.NewSessionServiceClient(conn), db
+}
+
+func TestAttachIsExclusive(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		session, err = storage.NewSession(tx, "work")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, err := client.Attach(ctx, &protov1.AttachRequest{SessionId: session.ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := client.Attach(context.Background(), &protov1.AttachRequest{SessionId: session.ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = second.Recv()
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestAttachUnknownSession(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	stream, err := client.Attach(context.Background(), &protov1.AttachRequest{SessionId: uuid.NewString()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+}
+
+func TestAttachTerminatedSession(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "done"); err != nil {
+			return err
+		}
+		return storage.UpdateSessionStatus(tx, session.ID, enums.Terminated)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Attach(context.Background(), &protov1.AttachRequest{SessionId: session.ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		stored, err := storage.GetSession(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		if stored.Status != enums.Terminated {
+			t.Fatalf("expected the session to stay terminated, got %v", stored.Status)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockSessionReleaseDropsEntry(t *testing.T) {
+	s := &Service{}
+	id := uuid.New()
+
+	release, ok := s.lockSession(id)
+	if !ok {
+		t.Fatal("expected lock to succeed")
+	}
+	release()
+
+	if _, ok := s.locks.Load(id); ok {
+		t.Fatal("expected release to remove the lock entry")
+	}
+}
+
+func TestLockSessionConcurrent(t *testing.T) {
+	s := &Service{}
+	id := uuid.New()
+
+	release, ok := s.lockSession(id)
+	if !ok {
+		t.Fatal("expected first lock to succeed")
+	}
+
+	var (
+		wg       sync.WaitGroup
+		acquired atomic.Int32
+	)
+
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := s.lockSession(id); ok {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired.Load() != 0 {
+		t.Fatalf("expected lock to be held, %d goroutines acquired it", acquired.Load())
+	}
+
+	release()
+
+	if _, ok := s.lockSession(id); !ok {
+		t.Fatal("expected lock to be free after release")
+	}
+}
+
+func TestReadOnlyService(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := storage.NewSession(tx, "existing")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = bbolt.Open(path, 0600, &bbolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	s := &Service{Db: db, ReadOnly: true}
+
+	_, err = s.CreateSession(context.Background(), &protov1.CreateSessionRequest{Name: "new"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+
+	response, err := s.ListSessions(context.Background(), &protov1.ListSessionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Sessions) != 1 || response.Sessions[0].Name != "existing" {
+		t.Fatalf("unexpected sessions: %v", response.Sessions)
+	}
+}
+
+func TestDeleteSessionReportsCounts(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "doomed"); err != nil {
+			return err
+		}
+		window, err := storage.NewWindow(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		_, err = storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := client.DeleteSession(context.Background(), &protov1.DeleteSessionRequest{SessionId: session.ID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.DeletedWindows != 1 || response.DeletedPanes != 1 {
+		t.Fatalf("expected 1 window and 1 pane deleted, got %d and %d", response.DeletedWindows, response.DeletedPanes)
+	}
+}
+
+func TestCompleteNames(t *testing.T) {
+	client, db := newTestClient(t)
+
+	response, err := client.CompleteNames(context.Background(), &protov1.CompleteNamesRequest{Prefix: "w"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Names) != 0 {
+		t.Fatalf("expected no names on an empty db, got %v", response.Names)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range []string{"work-b", "home", "work-a", "wiki"} {
+			if _, err := storage.NewSession(tx, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	response, err = client.CompleteNames(context.Background(), &protov1.CompleteNamesRequest{Prefix: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(response.Names, ","); got != "work-a,work-b" {
+		t.Fatalf("expected work-a,work-b, got %s", got)
+	}
+
+	response, err = client.CompleteNames(context.Background(), &protov1.CompleteNamesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Names) != 4 {
+		t.Fatalf("expected every name for an empty prefix, got %v", response.Names)
+	}
+}
+
+func TestCreateSessionDuplicateNameErrorCode(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	request := &protov1.CreateSessionRequest{Name: "twice"}
+	if _, err := client.CreateSession(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.CreateSession(context.Background(), request)
+	st, _ := status.FromError(err)
+	if st.Code() != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+
+	var code protov1.ErrorCode
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*protov1.ErrorDetail); ok {
+			code = d.Code
+		}
+	}
+	if code != protov1.ErrorCode_IRA_NAME_TAKEN {
+		t.Fatalf("expected %s, got %s", protov1.ErrorCode_IRA_NAME_TAKEN, code)
+	}
+}
+
+func TestCreateSessionFull(t *testing.T) {
+	client, db := newTestClient(t)
+	cwd := t.TempDir()
+
+	response, err := client.CreateSessionFull(context.Background(), &protov1.CreateSessionFullRequest{
+		Name:   "full",
+		Width:  120,
+		Height: 40,
+		Cwd:    cwd,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetSession().GetName() != "full" {
+		t.Fatalf("expected session full, got %q", response.GetSession().GetName())
+	}
+	if got := response.GetPane(); got.GetWidth() != 120 || got.GetHeight() != 40 || got.GetCwd() != cwd {
+		t.Fatalf("expected a 120x40 pane in %s, got %v", cwd, got)
+	}
+	if response.GetPane().GetWindowId() != response.GetWindow().GetId() {
+		t.Fatal("expected the pane to belong to the returned window")
+	}
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		id := uuid.MustParse(response.GetSession().GetId())
+
+		windows, err := storage.GetWindows(tx, id)
+		if err != nil {
+			return err
+		}
+		if len(windows) != 1 {
+			t.Fatalf("expected exactly 1 window, got %d", len(windows))
+		}
+
+		panes, err := storage.GetAllPanes(tx, id)
+		if err != nil {
+			return err
+		}
+		if len(panes) != 1 {
+			t.Fatalf("expected exactly 1 pane, got %d", len(panes))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is created when any step fails.
+	_, err = client.CreateSessionFull(context.Background(), &protov1.CreateSessionFullRequest{
+		Name:   "relative",
+		Width:  80,
+		Height: 24,
+		Cwd:    "not/absolute",
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a relative cwd, got %v", err)
+	}
+	if _, err := client.CreateSessionFull(context.Background(), &protov1.CreateSessionFullRequest{Name: "tiny", Width: -1, Height: 24}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for a negative size, got %v", err)
+	}
+
+	list, err := client.ListSessions(context.Background(), &protov1.ListSessionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetSessions()) != 1 {
+		t.Fatalf("expected only the first session to exist, got %d", len(list.GetSessions()))
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	client, db := newTestClient(t)
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := storage.NewSession(tx, "taken")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{"taken": false, "free": true} {
+		response, err := client.CheckName(context.Background(), &protov1.CheckNameRequest{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.GetAvailable() != want {
+			t.Fatalf("%s: expected available=%v, got %v", name, want, response.GetAvailable())
+		}
+	}
+
+	if _, err := client.CheckName(context.Background(), &protov1.CheckNameRequest{Name: "bad name!"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+// fakeBackend serves canned results so error mapping can be tested without a
+// db. Unset funcs fail the call with errNotFaked.
+type fakeBackend struct {
+	createSession func(name string) (storage.SessionEntry, error)
+	deleteSession func(id uuid.UUID) (int, int, error)
+}
+
+var errNotFaked = errors.New("not faked")
+
+func (f *fakeBackend) CreateSession(ctx context.Context, name string) (storage.SessionEntry, error) {
+	if f.createSession == nil {
+		return storage.SessionEntry{}, errNotFaked
+	}
+	return f.createSession(name)
+}
+
+func (f *fakeBackend) GetSessions(ctx context.Context) ([]storage.SessionEntry, error) {
+	return nil, errNotFaked
+}
+
+func (f *fakeBackend) GetSessionsByIDs(ctx context.Context, ids []uuid.UUID) ([]storage.SessionEntry, []uuid.UUID, error) {
+	return nil, nil, errNotFaked
+}
+
+func (f *fakeBackend) GetSessionNames(ctx context.Context, prefix string) ([]string, error) {
+	return nil, errNotFaked
+}
+
+func (f *fakeBackend) IsSessionNameAvailable(ctx context.Context, name string) (bool, error) {
+	return false, errNotFaked
+}
+
+func (f *fakeBackend) DeleteSession(ctx context.Context, id uuid.UUID) (int, int, error) {
+	if f.deleteSession == nil {
+		return 0, 0, errNotFaked
+	}
+	return f.deleteSession(id)
+}
+
+func TestErrorMappingWithFakeBackend(t *testing.T) {
+	s := &Service{Backend: &fakeBackend{
+		createSession: func(name string) (storage.SessionEntry, error) {
+			return storage.SessionEntry{}, storage.ErrSessionAlreadyExists
+		},
+		deleteSession: func(id uuid.UUID) (int, int, error) {
+			return 0, 0, fmt.Errorf("delete %s: %w", id, storage.ErrSessionNotFound)
+		},
+	}}
+
+	if _, err := s.CreateSession(context.Background(), &protov1.CreateSessionRequest{Name: "taken"}); status.Code(err) != codes.AlreadyExists {
+		t.Fatalf("expected AlreadyExists, got %v", err)
+	}
+
+	_, err := s.DeleteSession(context.Background(), &protov1.DeleteSessionRequest{SessionId: uuid.NewString()})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound for a wrapped ErrSessionNotFound, got %v", err)
+	}
+
+	if _, err := s.ListSessions(context.Background(), &protov1.ListSessionsRequest{}); status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal for an unmapped error, got %v", err)
+	}
+}
+
+func TestErrorMappingReasons(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{storage.ErrWindowNotEmpty, codes.FailedPrecondition, rpcerr.ReasonWindowNotEmpty},
+		{storage.ErrReadOnlyTx, codes.FailedPrecondition, rpcerr.ReasonReadOnly},
+		{storage.ErrNameGenerationFailed, codes.FailedPrecondition, rpcerr.ReasonNameGenerationFailed},
+		{storage.ErrSchemaTooNew, codes.FailedPrecondition, rpcerr.ReasonSchemaTooNew},
+		{storage.ErrInvalidWindowNameAlphabet, codes.FailedPrecondition, rpcerr.ReasonInvalidWindowNameAlphabet},
+		{storage.ErrInvalidSessionStatus, codes.InvalidArgument, rpcerr.ReasonInvalidSessionStatus},
+	}
+
+	for _, c := range cases {
+		s := &Service{Backend: &fakeBackend{
+			deleteSession: func(id uuid.UUID) (int, int, error) {
+				return 0, 0, fmt.Errorf("delete %s: %w", id, c.err)
+			},
+		}}
+
+		_, err := s.DeleteSession(context.Background(), &protov1.DeleteSessionRequest{SessionId: uuid.NewString()})
+		st := status.Convert(err)
+		if st.Code() != c.code {
+			t.Fatalf("%v: expected %v, got %v", c.err, c.code, err)
+		}
+
+		var reason string
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.ErrorInfo); ok {
+				reason = info.Reason
+			}
+		}
+		if reason != c.reason {
+			t.Fatalf("%v: expected reason %s, got %q", c.err, c.reason, reason)
+		}
+	}
+}
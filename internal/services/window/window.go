@@ -0,0 +1,108 @@
+package window
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cchirag/ira/internal/services/mapping"
+	"github.com/cchirag/ira/internal/services/rpcerr"
+	"github.com/cchirag/ira/internal/storage"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const eventBuffer = 64
+
+type Service struct {
+	protov1.UnimplementedWindowServiceServer
+	Db *bbolt.DB
+}
+
+// baseIndex reads the session's base-index for mapping window numbers. A
+// deleted session maps with 0 so its final delete events still go out.
+func (s *Service) baseIndex(sessionId uuid.UUID) (int, error) {
+	var baseIndex int
+
+	err := s.Db.View(func(tx *bbolt.Tx) error {
+		var err error
+		baseIndex, err = storage.GetBaseIndex(tx, sessionId)
+		if errors.Is(err, storage.ErrSessionNotFound) || errors.Is(err, storage.ErrSessionBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+
+	return baseIndex, err
+}
+
+func (s *Service) GetWindowByIndex(ctx context.Context, request *protov1.GetWindowByIndexRequest) (*protov1.GetWindowByIndexResponse, error) {
+	sessionId, err := uuid.Parse(request.GetSessionId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	var window storage.WindowEntry
+	var baseIndex int
+
+	if err := s.Db.View(func(tx *bbolt.Tx) error {
+		var err error
+		if baseIndex, err = storage.GetBaseIndex(tx, sessionId); err != nil {
+			return err
+		}
+		window, err = storage.GetWindowByIndex(tx, sessionId, int(request.GetIndex())-baseIndex)
+		return err
+	}); err != nil {
+		return nil, rpcerr.FromStorage(err)
+	}
+
+	return &protov1.GetWindowByIndexResponse{
+		Window: mapping.WindowToProto(window, baseIndex),
+	}, nil
+}
+
+func (s *Service) WatchWindows(request *protov1.WatchWindowsRequest, stream grpc.ServerStreamingServer[protov1.WindowEvent]) error {
+	sessionId, err := uuid.Parse(request.GetSessionId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, "invalid session id")
+	}
+
+	if err := s.Db.View(func(tx *bbolt.Tx) error {
+		_, err := storage.GetSession(tx, sessionId)
+		return err
+	}); err != nil {
+		return rpcerr.FromStorage(err)
+	}
+
+	events, cancel := storage.WindowEvents.Subscribe(eventBuffer)
+	defer cancel()
+
+	// Headers tell the client the subscription is live.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if event.Window.SessionID != sessionId {
+				continue
+			}
+
+			baseIndex, err := s.baseIndex(sessionId)
+			if err != nil {
+				return rpcerr.FromStorage(err)
+			}
+
+			if err := stream.Send(mapping.WindowEventToProto(event, baseIndex)); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package window
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/cchirag/ira/internal/storage/storagetest"
+	protov1 "github.com/cchirag/ira/proto/gen/services/v1"
+	"go.etcd.io/bbolt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) (protov1.WindowServiceClient, *bbolt.DB) {
+	t.Helper()
+
+	db := storagetest.OpenDB(t)
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	protov1.RegisterWindowServiceServer(server, &Service{Db: db})
+
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	return protov1.NewWindowServiceClient(conn), db
+}
+
+func TestWatchWindowsIsScopedToSession(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session, other storage.SessionEntry
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = storage.NewSession(tx, "watched"); err != nil {
+			return err
+		}
+		other, err = storage.NewSession(tx, "ignored")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchWindows(ctx, &protov1.WatchWindowsRequest{
+		SessionId: session.ID.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		// Windows of other sessions must not leak through.
+		if _, err := storage.NewWindow(tx, other.ID); err != nil {
+			return err
+		}
+
+		window, err := storage.NewWindow(tx, session.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := storage.RenameWindow(tx, session.ID, window.ID, "logs"); err != nil {
+			return err
+		}
+
+		return storage.DeleteWindow(tx, session.ID, window.ID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []protov1.WindowEventType{
+		protov1.WindowEventType_WINDOW_EVENT_TYPE_CREATED,
+		protov1.WindowEventType_WINDOW_EVENT_TYPE_RENAMED,
+		protov1.WindowEventType_WINDOW_EVENT_TYPE_DELETED,
+	}
+
+	for _, want := range expected {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != want {
+			t.Fatalf("expected %s, got %s", want, event.Type)
+		}
+		if event.Window.SessionId != session.ID.String() {
+			t.Fatalf("unexpected event for session %s", event.Window.SessionId)
+		}
+	}
+}
+
+func TestWatchWindowsReportsNewIndex(t *testing.T) {
+	client, db := newTestClient(t)
+
+	var session storage.SessionEntry
+	var windows []storage.WindowEntry
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		var err error
+		session, err = storage.NewSession(tx, "reorder")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := db.Update(func(tx *bbolt.Tx) error {
+			window, err := storage.NewWindow(tx, session.ID)
+			windows = append(windows, window)
+			return err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchWindows(ctx, &protov1.WatchWindowsRequest{
+		SessionId: session.ID.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		return storage.MoveWindow(tx, session.ID, windows[1].ID, 0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	indices := map[string]int32{}
+	for range 2 {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != protov1.WindowEventType_WINDOW_EVENT_TYPE_MOVED {
+			t.Fatalf("expected MOVED, got %s", event.Type)
+		}
+		indices[event.Window.Id] = event.Window.Index
+	}
+
+	if indices[windows[1].ID.String()] != 0 || indices[windows[0].ID.String()] != 1 {
+		t.Fatalf("unexpected indices in move events: %v", indices)
+	}
+}
+
+func TestGetWindowByIndexHonorsBaseIndex(t *testing.T) {
+	client, db := newTestClient(t)
+	seeded := storagetest.SeedSession(t, db, "numbered", 2, 0)
+	sessionID, windowIDs := seeded.SessionID, seeded.WindowIDs
+
+	get := func(index int32) (*protov1.Window, error) {
+		response, err := client.GetWindowByIndex(context.Background(), &protov1.GetWindowByIndexRequest{
+			SessionId: sessionID.String(),
+			Index:     index,
+		})
+		return response.GetWindow(), err
+	}
+
+	window, err := get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if window.GetId() != windowIDs[0].String() || window.GetIndex() != 0 {
+		t.Fatalf("expected the first window numbered 0, got %v", window)
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		return storage.SetSessionOption(tx, sessionID, storage.BaseIndexOption, "1")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, id := range windowIDs {
+		window, err := get(int32(i + 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if window.GetId() != id.String() || window.GetIndex() != int32(i+1) {
+			t.Fatalf("expected window %s numbered %d, got %v", id, i+1, window)
+		}
+	}
+
+	if _, err := get(0); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound below the base index, got %v", err)
+	}
+
+	// Stored indices are untouched.
+	if err := db.View(func(tx *bbolt.Tx) error {
+		first, err := storage.GetWindow(tx, sessionID, windowIDs[0])
+		if err != nil {
+			return err
+		}
+		if first.Index != 0 {
+			t.Fatalf("expected stored index 0, got %d", first.Index)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,97 @@
+package storage
+
+// The audit log is an append-only record of mutations.
+//
+// BoltDB layout:
+//
+//   __audit__ (bucket)
+//     └── <unix-nanos (8 bytes, big endian)><uuid (16 bytes)> → JSON(AuditEntry)
+//
+// Notes:
+//   - Keys sort by time, so reading since a point in time is a single seek.
+//   - ResetDatabase leaves the log alone.
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// AuditBucket holds audit entries keyed by time.
+var AuditBucket = []byte("__audit__")
+
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	EntityType string    `json:"entityType"`
+	EntityID   string    `json:"entityId,omitempty"`
+	Actor      string    `json:"actor"`
+}
+
+// RecordAudit appends entry to the audit log. A zero Time is set to now.
+func RecordAudit(tx *bbolt.Tx, entry AuditEntry) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = now()
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(AuditBucket)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	id := uuid.New()
+	key := append(auditTimeKey(entry.Time), id[:]...)
+
+	return bucket.Put(key, data)
+}
+
+// GetAuditLog returns the entries recorded at or after since, oldest first. A
+// zero since returns the whole log.
+func GetAuditLog(tx *bbolt.Tx, since time.Time) ([]AuditEntry, error) {
+	if tx == nil {
+		return nil, ErrTxnNotFound
+	}
+
+	entries := make([]AuditEntry, 0)
+
+	bucket := tx.Bucket(AuditBucket)
+	if bucket == nil {
+		return entries, nil
+	}
+
+	cursor := bucket.Cursor()
+
+	k, v := cursor.First()
+	if !since.IsZero() {
+		k, v = cursor.Seek(auditTimeKey(since))
+	}
+
+	for ; k != nil; k, v = cursor.Next() {
+		var entry AuditEntry
+		if err := json.Unmarshal(v, &entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func auditTimeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
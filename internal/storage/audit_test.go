@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestGetAuditLogSince(t *testing.T) {
+	db := openTestDB(t)
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for i, method := range []string{"first", "second", "third"} {
+			if err := RecordAudit(tx, AuditEntry{
+				Time:       base.Add(time.Duration(i) * time.Minute),
+				Method:     method,
+				EntityType: "session",
+				Actor:      "local",
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		all, err := GetAuditLog(tx, time.Time{})
+		if err != nil {
+			return err
+		}
+		if len(all) != 3 || all[0].Method != "first" {
+			t.Fatalf("expected the whole log oldest first, got %+v", all)
+		}
+
+		recent, err := GetAuditLog(tx, base.Add(time.Minute))
+		if err != nil {
+			return err
+		}
+		if len(recent) != 2 || recent[0].Method != "second" || recent[1].Method != "third" {
+			t.Fatalf("expected the last 2 entries, got %+v", recent)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// Backend is the session API the gRPC services call, so they can be tested
+// against a fake instead of a bbolt file. Store implements it by running each
+// call in its own transaction. A missing session bucket reads as no sessions.
+type Backend interface {
+	CreateSession(ctx context.Context, name string) (SessionEntry, error)
+	GetSessions(ctx context.Context) ([]SessionEntry, error)
+	GetSessionsByIDs(ctx context.Context, ids []uuid.UUID) ([]SessionEntry, []uuid.UUID, error)
+	GetSessionNames(ctx context.Context, prefix string) ([]string, error)
+	IsSessionNameAvailable(ctx context.Context, name string) (bool, error)
+	DeleteSession(ctx context.Context, id uuid.UUID) (windows, panes int, err error)
+}
+
+var _ Backend = (*Store)(nil)
+
+func (s *Store) CreateSession(ctx context.Context, name string) (SessionEntry, error) {
+	var session SessionEntry
+
+	err := s.Update(func(tx *bbolt.Tx) error {
+		var err error
+		session, err = NewSession(tx, name)
+		return err
+	})
+
+	return session, err
+}
+
+func (s *Store) GetSessions(ctx context.Context) ([]SessionEntry, error) {
+	var sessions []SessionEntry
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		var err error
+		sessions, err = GetSessionsCtx(ctx, tx)
+		return err
+	})
+
+	return sessions, err
+}
+
+func (s *Store) GetSessionsByIDs(ctx context.Context, ids []uuid.UUID) ([]SessionEntry, []uuid.UUID, error) {
+	var sessions []SessionEntry
+	var missing []uuid.UUID
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		var err error
+		sessions, missing, err = GetSessionsByIDs(tx, ids)
+		return err
+	})
+
+	return sessions, missing, err
+}
+
+func (s *Store) GetSessionNames(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		var err error
+		names, err = GetSessionNames(tx, prefix)
+		return err
+	})
+
+	return names, err
+}
+
+func (s *Store) IsSessionNameAvailable(ctx context.Context, name string) (bool, error) {
+	var available bool
+
+	err := s.View(func(tx *bbolt.Tx) error {
+		var err error
+		available, err = IsSessionNameAvailable(tx, name)
+		return err
+	})
+
+	return available, err
+}
+
+func (s *Store) DeleteSession(ctx context.Context, id uuid.UUID) (windows, panes int, err error) {
+	err = s.Update(func(tx *bbolt.Tx) error {
+		var err error
+		windows, panes, err = DeleteSessionCounts(tx, id)
+		return err
+	})
+
+	return windows, panes, err
+}
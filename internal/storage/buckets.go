@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+
+	"go.etcd.io/bbolt"
+)
+
+// StrictBuckets makes mutating functions that work on existing entries
+// (status/name updates and deletes) require their buckets to exist instead of
+// creating empty ones on the fly. A missing bucket is then reported with the
+// matching *BucketNotFound error. Off by default.
+var StrictBuckets = false
+
+// ErrReadOnlyTx is returned by mutating functions given a tx opened with
+// View. Calling db.Update from inside it instead would deadlock, since bbolt
+// allows only one writer.
+var ErrReadOnlyTx = errors.New("db txn is read-only")
+
+// writableTx checks that tx can be written to.
+func writableTx(tx *bbolt.Tx) error {
+	if tx == nil {
+		return ErrTxnNotFound
+	}
+
+	if !tx.Writable() {
+		return ErrReadOnlyTx
+	}
+
+	// Every storage write passes through here, so this is where the DB's
+	// commit hook is attached to transactions not opened by Store.Update.
+	hookCommit(tx)
+
+	return nil
+}
+
+func bucketForWrite(tx *bbolt.Tx, name []byte, missing error) (*bbolt.Bucket, error) {
+	if !StrictBuckets {
+		return tx.CreateBucketIfNotExists(name)
+	}
+
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return nil, missing
+	}
+
+	return bucket, nil
+}
+
+func subBucketForWrite(parent *bbolt.Bucket, key []byte, missing error) (*bbolt.Bucket, error) {
+	if !StrictBuckets {
+		return parent.CreateBucketIfNotExists(key)
+	}
+
+	bucket := parent.Bucket(key)
+	if bucket == nil {
+		return nil, missing
+	}
+
+	return bucket, nil
+}
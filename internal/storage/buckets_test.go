@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func withStrictBuckets(t *testing.T, strict bool) {
+	t.Helper()
+
+	original := StrictBuckets
+	StrictBuckets = strict
+	t.Cleanup(func() { StrictBuckets = original })
+}
+
+func TestStrictBucketsOnEmptyDB(t *testing.T) {
+	withStrictBuckets(t, true)
+	db := openTestDB(t)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		return UpdateSessionStatus(tx, uuid.New(), 0)
+	})
+	if !errors.Is(err, ErrSessionBucketNotFound) {
+		t.Fatalf("expected ErrSessionBucketNotFound, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if tx.Bucket(SessionBucket) != nil {
+			t.Fatal("expected strict mode not to create the session bucket")
+		}
+		return nil
+	})
+
+	sessionID, _ := seedWindow(t, db)
+
+	// A session without windows has no WINDOW sub-bucket; strict mode
+	// reports that instead of creating an empty one.
+	err = db.Update(func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "no-windows")
+		if err != nil {
+			return err
+		}
+		return DeleteWindow(tx, session.ID, uuid.New())
+	})
+	if !errors.Is(err, ErrWindowSessionBucketNotFound) {
+		t.Fatalf("expected ErrWindowSessionBucketNotFound, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := DeleteSession(tx, sessionID); err != nil {
+			t.Fatalf("expected delete of an existing session to work in strict mode, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestLenientBucketsOnEmptyDB(t *testing.T) {
+	withStrictBuckets(t, false)
+	db := openTestDB(t)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		return UpdateSessionStatus(tx, uuid.New(), 0)
+	})
+	if !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "no-windows")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := DeleteWindow(tx, session.ID, uuid.New()); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("expected ErrWindowNotFound, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestExportedBucketNames(t *testing.T) {
+	db := openTestDB(t)
+
+	var session SessionEntry
+	var window WindowEntry
+	var pane PaneEntry
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = NewSession(tx, "exported"); err != nil {
+			return err
+		}
+		if window, err = NewWindow(tx, session.ID); err != nil {
+			return err
+		}
+		pane, err = NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "/tmp")
+		return err
+	})
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		sessions := tx.Bucket(SessionBucket)
+		if sessions == nil || sessions.Get([]byte(session.ID.String())) == nil {
+			t.Fatalf("expected session %s under %s", session.ID, SessionBucket)
+		}
+		if id := sessions.Bucket(SessionLookupBucket).Get([]byte(session.Name)); string(id) != session.ID.String() {
+			t.Fatalf("expected %s to map to %s, got %q", SessionLookupBucket, session.ID, id)
+		}
+		if countKeys(sessions.Bucket(SessionCreatedIndexBucket)) != 1 {
+			t.Fatalf("expected one entry in %s", SessionCreatedIndexBucket)
+		}
+		if tx.Bucket(WindowBucket).Bucket([]byte(session.ID.String())).Get([]byte(window.ID.String())) == nil {
+			t.Fatalf("expected window %s under %s", window.ID, WindowBucket)
+		}
+		if tx.Bucket(PaneBucket).Bucket([]byte(window.ID.String())).Get([]byte(pane.ID.String())) == nil {
+			t.Fatalf("expected pane %s under %s", pane.ID, PaneBucket)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteInsideViewTx(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "read-only-tx")
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		if _, err := NewSession(tx, "inside-view"); !errors.Is(err, ErrReadOnlyTx) {
+			t.Fatalf("expected ErrReadOnlyTx from NewSession, got %v", err)
+		}
+		if err := DeleteSession(tx, sessionID); !errors.Is(err, ErrReadOnlyTx) {
+			t.Fatalf("expected ErrReadOnlyTx from DeleteSession, got %v", err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := GetSessionByName(tx, "inside-view"); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected no session to be created, got %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,18 @@
+package storage
+
+import "go.etcd.io/bbolt"
+
+// CheckIntegrity runs bbolt's consistency check in a read transaction and
+// returns every problem found. A healthy DB returns no errors.
+func CheckIntegrity(db *bbolt.DB) ([]error, error) {
+	var problems []error
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		for err := range tx.Check() {
+			problems = append(problems, err)
+		}
+		return nil
+	})
+
+	return problems, err
+}
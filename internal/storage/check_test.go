@@ -0,0 +1,16 @@
+package storage
+
+import "testing"
+
+func TestCheckIntegrityHealthyDB(t *testing.T) {
+	db := openTestDB(t)
+	buildTree(t, db, "healthy")
+
+	problems, err := CheckIntegrity(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("expected no integrity problems, got %v", problems)
+	}
+}
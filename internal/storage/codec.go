@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"encoding/json"
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// Codec encodes the session, window and pane entries stored in the db. It must
+// read and write the same JSON as encoding/json, honouring json struct tags,
+// so that a db written with one codec can be read with another. It exists so a
+// faster JSON encoder can replace the standard library's.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the default Codec, backed by encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// codecs holds the Codec of every Store that set one, keyed by its *bbolt.DB,
+// so marshalEntry and unmarshalEntry can find it from the tx.
+var codecs sync.Map
+
+// codecFor returns the Codec set for tx's DB, or JSONCodec.
+func codecFor(tx *bbolt.Tx) Codec {
+	if tx != nil {
+		if codec, ok := codecs.Load(tx.DB()); ok {
+			return codec.(Codec)
+		}
+	}
+
+	return JSONCodec{}
+}
+
+// SetCodec makes every transaction on the Store's DB encode and decode entries
+// with codec. A nil codec restores JSONCodec. Set it before the Store is used.
+func (s *Store) SetCodec(codec Codec) {
+	if codec == nil {
+		codecs.Delete(s.db)
+		return
+	}
+
+	codecs.Store(s.db, codec)
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// countingCodec is JSONCodec that counts its calls.
+type countingCodec struct {
+	JSONCodec
+	marshals, unmarshals atomic.Int64
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshals.Add(1)
+	return c.JSONCodec.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshals.Add(1)
+	return c.JSONCodec.Unmarshal(data, v)
+}
+
+func TestStoreUsesCustomCodec(t *testing.T) {
+	store := NewStore(openTestDB(t))
+	codec := &countingCodec{}
+	store.SetCodec(codec)
+	t.Cleanup(func() { store.SetCodec(nil) })
+
+	var sessionID uuid.UUID
+	if err := store.Update(func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "codec")
+		sessionID = session.ID
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if codec.marshals.Load() == 0 {
+		t.Fatal("expected NewSession to marshal with the custom codec")
+	}
+
+	if err := store.View(func(tx *bbolt.Tx) error {
+		_, err := GetSession(tx, sessionID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if codec.unmarshals.Load() == 0 {
+		t.Fatal("expected GetSession to unmarshal with the custom codec")
+	}
+
+	// Another DB keeps the default codec.
+	other, err := bbolt.Open(filepath.Join(t.TempDir(), "other.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	before := codec.marshals.Load()
+	withTx(t, other, func(tx *bbolt.Tx) error {
+		_, err := NewSession(tx, "default-codec")
+		return err
+	})
+	if codec.marshals.Load() != before {
+		t.Fatal("expected a DB without a codec set to use JSONCodec")
+	}
+}
+
+// BenchmarkCodecPanes marshals and unmarshals 10k panes with each codec. Add a
+// Codec to the table to compare it with the standard library.
+func BenchmarkCodecPanes(b *testing.B) {
+	panes := make([]PaneEntry, 10_000)
+	for i := range panes {
+		panes[i] = PaneEntry{
+			ID:            uuid.New(),
+			SsessionID:    uuid.New(),
+			WindowID:      uuid.New(),
+			Title:         fmt.Sprintf("pane-%d", i),
+			Cwd:           "/home/user/project",
+			Width:         80,
+			Height:        24,
+			X:             int32(i % 4 * 80),
+			Y:             int32(i / 4 % 4 * 24),
+			Order:         i,
+			SchemaVersion: CurrentSchemaVersion,
+		}
+	}
+
+	for _, bench := range []struct {
+		name  string
+		codec Codec
+	}{
+		{"json", JSONCodec{}},
+	} {
+		encoded := make([][]byte, len(panes))
+
+		b.Run(bench.name+"/marshal", func(b *testing.B) {
+			for b.Loop() {
+				for i := range panes {
+					data, err := bench.codec.Marshal(panes[i])
+					if err != nil {
+						b.Fatal(err)
+					}
+					encoded[i] = data
+				}
+			}
+		})
+
+		b.Run(bench.name+"/unmarshal", func(b *testing.B) {
+			for b.Loop() {
+				for _, data := range encoded {
+					var pane PaneEntry
+					if err := bench.codec.Unmarshal(data, &pane); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// commitHooks holds the commit hook of every Store that set one, keyed by its
+// *bbolt.DB, so writes on the raw DB from Store.DB are hooked too.
+var commitHooks sync.Map
+
+type commitHook struct {
+	fn func(stats bbolt.TxStats)
+
+	mu sync.Mutex
+	// last is the tx the hook was last attached to. bbolt runs one writable
+	// tx per DB at a time, so this is enough to attach it once per tx.
+	last *bbolt.Tx
+}
+
+// hookCommit attaches the commit hook of tx's DB, if it has one, to tx. It is
+// safe to call more than once per tx; the hook runs once when tx commits.
+func hookCommit(tx *bbolt.Tx) {
+	found, ok := commitHooks.Load(tx.DB())
+	if !ok {
+		return
+	}
+
+	hook := found.(*commitHook)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+
+	if hook.last == tx {
+		return
+	}
+	hook.last = tx
+
+	tx.OnCommit(func() {
+		hook.fn(tx.Stats())
+	})
+}
+
+// SetCommitHook makes fn run with the transaction's stats after every write
+// transaction on the Store's DB that commits and went through Store.Update or
+// a storage function, including ones run on the DB from Store.DB. It is meant
+// for tuning, e.g. LogTxStats. A nil fn removes the hook.
+func (s *Store) SetCommitHook(fn func(stats bbolt.TxStats)) {
+	if fn == nil {
+		commitHooks.Delete(s.db)
+		return
+	}
+
+	commitHooks.Store(s.db, &commitHook{fn: fn})
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// CorruptRecord is an entry a lenient list skipped because it could not be
+// decoded. Err wraps ErrCorruptEntry.
+type CorruptRecord struct {
+	Key string
+	Err error
+}
+
+// skipCorrupt records err against key and swallows it when the scan is
+// lenient (corrupt is non-nil) and err is a decode failure. Any other error is
+// returned unchanged so the scan aborts.
+func skipCorrupt(corrupt *[]CorruptRecord, key []byte, err error) error {
+	if corrupt == nil || !errors.Is(err, ErrCorruptEntry) {
+		return err
+	}
+
+	*corrupt = append(*corrupt, CorruptRecord{Key: string(key), Err: err})
+
+	return nil
+}
+
+// GetSessionsLenient is GetSessions but skips entries that fail to decode and
+// returns their keys alongside the sessions that could be read.
+func GetSessionsLenient(tx *bbolt.Tx) ([]SessionEntry, []CorruptRecord, error) {
+	var corrupt []CorruptRecord
+
+	sessions, err := getSessions(context.Background(), tx, &corrupt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sessions, corrupt, nil
+}
+
+// GetWindowsLenient is the lenient counterpart of GetWindows.
+func GetWindowsLenient(tx *bbolt.Tx, sessionId uuid.UUID) ([]WindowEntry, []CorruptRecord, error) {
+	var corrupt []CorruptRecord
+
+	windows, err := getWindows(tx, sessionId, &corrupt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return windows, corrupt, nil
+}
+
+// GetPanesLenient is the lenient counterpart of GetPanes.
+func GetPanesLenient(tx *bbolt.Tx, sessionId, windowId uuid.UUID) ([]PaneEntry, []CorruptRecord, error) {
+	var corrupt []CorruptRecord
+
+	panes, err := getPanes(tx, sessionId, windowId, &corrupt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return panes, corrupt, nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestGetSessionsLenient(t *testing.T) {
+	db := openTestDB(t)
+	bad := uuid.New()
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for _, name := range []string{"good-a", "good-b"} {
+			if _, err := NewSession(tx, name); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(SessionBucket).Put([]byte(bad.String()), []byte("garbage"))
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := GetSessions(tx); !errors.Is(err, ErrCorruptEntry) {
+			t.Fatalf("expected strict list to fail with ErrCorruptEntry, got %v", err)
+		}
+
+		sessions, corrupt, err := GetSessionsLenient(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 2 {
+			t.Fatalf("expected 2 good sessions, got %d", len(sessions))
+		}
+		if len(corrupt) != 1 || corrupt[0].Key != bad.String() {
+			t.Fatalf("expected %s to be reported corrupt, got %+v", bad, corrupt)
+		}
+		if !errors.Is(corrupt[0].Err, ErrCorruptEntry) {
+			t.Fatalf("expected record error to wrap ErrCorruptEntry, got %v", corrupt[0].Err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// SessionDiff describes the structural differences between two sessions.
+// Windows are matched by Index and panes by their X/Y position, since the two
+// sessions never share UUIDs.
+type SessionDiff struct {
+	WindowsOnlyInA []int        `json:"windowsOnlyInA"`
+	WindowsOnlyInB []int        `json:"windowsOnlyInB"`
+	Windows        []WindowDiff `json:"windows"`
+}
+
+// WindowDiff lists the pane differences of two windows at the same Index.
+type WindowDiff struct {
+	Index        int            `json:"index"`
+	PanesOnlyInA []PaneGeometry `json:"panesOnlyInA"`
+	PanesOnlyInB []PaneGeometry `json:"panesOnlyInB"`
+	Resized      []PaneResize   `json:"resized"`
+}
+
+// PaneResize is a pane found at the same position in both windows but with a
+// different size.
+type PaneResize struct {
+	A PaneGeometry `json:"a"`
+	B PaneGeometry `json:"b"`
+}
+
+func (d SessionDiff) Empty() bool {
+	return len(d.WindowsOnlyInA) == 0 && len(d.WindowsOnlyInB) == 0 && len(d.Windows) == 0
+}
+
+func (d WindowDiff) empty() bool {
+	return len(d.PanesOnlyInA) == 0 && len(d.PanesOnlyInB) == 0 && len(d.Resized) == 0
+}
+
+type panePosition struct {
+	x, y int32
+}
+
+func DiffSessions(tx *bbolt.Tx, a, b uuid.UUID) (SessionDiff, error) {
+	if tx == nil {
+		return SessionDiff{}, ErrTxnNotFound
+	}
+
+	windowsA, err := windowsByIndex(tx, a)
+	if err != nil {
+		return SessionDiff{}, err
+	}
+
+	windowsB, err := windowsByIndex(tx, b)
+	if err != nil {
+		return SessionDiff{}, err
+	}
+
+	diff := SessionDiff{
+		WindowsOnlyInA: []int{},
+		WindowsOnlyInB: []int{},
+		Windows:        []WindowDiff{},
+	}
+
+	indices := make([]int, 0, len(windowsA)+len(windowsB))
+	for index := range windowsA {
+		indices = append(indices, index)
+	}
+	for index := range windowsB {
+		if _, ok := windowsA[index]; !ok {
+			indices = append(indices, index)
+		}
+	}
+	sort.Ints(indices)
+
+	for _, index := range indices {
+		windowA, inA := windowsA[index]
+		windowB, inB := windowsB[index]
+
+		switch {
+		case !inB:
+			diff.WindowsOnlyInA = append(diff.WindowsOnlyInA, index)
+		case !inA:
+			diff.WindowsOnlyInB = append(diff.WindowsOnlyInB, index)
+		default:
+			windowDiff, err := diffWindows(tx, windowA, windowB)
+			if err != nil {
+				return SessionDiff{}, err
+			}
+
+			if !windowDiff.empty() {
+				diff.Windows = append(diff.Windows, windowDiff)
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+func windowsByIndex(tx *bbolt.Tx, sessionId uuid.UUID) (map[int]WindowEntry, error) {
+	windows, err := GetWindows(tx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	byIndex := make(map[int]WindowEntry, len(windows))
+	for _, window := range windows {
+		byIndex[window.Index] = window
+	}
+
+	return byIndex, nil
+}
+
+func panesByPosition(tx *bbolt.Tx, window WindowEntry) (map[panePosition]PaneEntry, error) {
+	panes, err := GetPanes(tx, window.SessionID, window.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	byPosition := make(map[panePosition]PaneEntry, len(panes))
+	for _, pane := range panes {
+		byPosition[panePosition{pane.X, pane.Y}] = pane
+	}
+
+	return byPosition, nil
+}
+
+func geometryOf(pane PaneEntry) PaneGeometry {
+	return PaneGeometry{ID: pane.ID, X: pane.X, Y: pane.Y, Width: pane.Width, Height: pane.Height}
+}
+
+func diffWindows(tx *bbolt.Tx, a, b WindowEntry) (WindowDiff, error) {
+	panesA, err := panesByPosition(tx, a)
+	if err != nil {
+		return WindowDiff{}, err
+	}
+
+	panesB, err := panesByPosition(tx, b)
+	if err != nil {
+		return WindowDiff{}, err
+	}
+
+	diff := WindowDiff{
+		Index:        a.Index,
+		PanesOnlyInA: []PaneGeometry{},
+		PanesOnlyInB: []PaneGeometry{},
+		Resized:      []PaneResize{},
+	}
+
+	for position, paneA := range panesA {
+		paneB, ok := panesB[position]
+		if !ok {
+			diff.PanesOnlyInA = append(diff.PanesOnlyInA, geometryOf(paneA))
+			continue
+		}
+
+		if paneA.Width != paneB.Width || paneA.Height != paneB.Height {
+			diff.Resized = append(diff.Resized, PaneResize{A: geometryOf(paneA), B: geometryOf(paneB)})
+		}
+	}
+
+	for position, paneB := range panesB {
+		if _, ok := panesA[position]; !ok {
+			diff.PanesOnlyInB = append(diff.PanesOnlyInB, geometryOf(paneB))
+		}
+	}
+
+	byPosition := func(geometries []PaneGeometry) {
+		sort.Slice(geometries, func(i, j int) bool {
+			if geometries[i].Y != geometries[j].Y {
+				return geometries[i].Y < geometries[j].Y
+			}
+			return geometries[i].X < geometries[j].X
+		})
+	}
+	byPosition(diff.PanesOnlyInA)
+	byPosition(diff.PanesOnlyInB)
+	sort.Slice(diff.Resized, func(i, j int) bool {
+		if diff.Resized[i].A.Y != diff.Resized[j].A.Y {
+			return diff.Resized[i].A.Y < diff.Resized[j].A.Y
+		}
+		return diff.Resized[i].A.X < diff.Resized[j].A.X
+	})
+
+	return diff, nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// buildTree creates a session with two windows of two side-by-side panes.
+func buildTree(t *testing.T, db *bbolt.DB, name string) uuid.UUID {
+	t.Helper()
+
+	var sessionID uuid.UUID
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, name)
+		sessionID = session.ID
+		return err
+	})
+
+	// One window per transaction so NewWindow assigns distinct indices.
+	for range 2 {
+		withTx(t, db, func(tx *bbolt.Tx) error {
+			window, err := NewWindow(tx, sessionID)
+			if err != nil {
+				return err
+			}
+
+			_, err = NewPanes(tx, sessionID, window.ID, []PaneSpec{
+				{Width: 40, Height: 24, X: 0, Y: 0},
+				{Width: 40, Height: 24, X: 40, Y: 0},
+			})
+			return err
+		})
+	}
+
+	return sessionID
+}
+
+func TestDiffSessions(t *testing.T) {
+	db := openTestDB(t)
+
+	original := buildTree(t, db, "original")
+	clone := buildTree(t, db, "clone")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		diff, err := DiffSessions(tx, original, clone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !diff.Empty() {
+			t.Fatalf("expected no structural diff, got %+v", diff)
+		}
+
+		windows, err := GetWindows(tx, clone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		panes, err := GetPanes(tx, clone, windows[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdatePaneSize(tx, clone, windows[0].ID, panes[0].ID, 20, 24); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewPane(tx, clone, windows[0].ID, 10, 10, 90, 0, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		diff, err = DiffSessions(tx, original, clone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diff.Windows) != 1 {
+			t.Fatalf("expected one differing window, got %+v", diff)
+		}
+		if len(diff.Windows[0].Resized) != 1 || len(diff.Windows[0].PanesOnlyInB) != 1 {
+			t.Fatalf("unexpected window diff: %+v", diff.Windows[0])
+		}
+
+		return nil
+	})
+}
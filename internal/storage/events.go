@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"github.com/cchirag/ira/internal/events"
+	"go.etcd.io/bbolt"
+)
+
+type SessionEventType int
+
+const (
+	SessionCreated SessionEventType = iota
+	SessionDeleted
+	SessionRenamed
+)
+
+type SessionEvent struct {
+	Type    SessionEventType
+	Session SessionEntry
+}
+
+type PaneEventType int
+
+const (
+	PaneCreated PaneEventType = iota
+	PaneDeleted
+	PaneResized
+	PaneMoved
+)
+
+type PaneEvent struct {
+	Type PaneEventType
+	Pane PaneEntry
+}
+
+type WindowEventType int
+
+const (
+	WindowCreated WindowEventType = iota
+	WindowDeleted
+	WindowRenamed
+	WindowMoved
+	WindowActivity
+)
+
+// WindowEvent carries the window as it is after the change. For WindowMoved
+// the window's Index is its new position.
+type WindowEvent struct {
+	Type   WindowEventType
+	Window WindowEntry
+}
+
+// SessionEvents carries session lifecycle events, published on commit like
+// PaneEvents.
+var SessionEvents = events.NewBroker[SessionEvent]()
+
+// PaneEvents carries pane lifecycle events. They are published only once the
+// transaction that produced them commits.
+var PaneEvents = events.NewBroker[PaneEvent]()
+
+// WindowEvents carries window lifecycle events, published on commit like
+// PaneEvents.
+var WindowEvents = events.NewBroker[WindowEvent]()
+
+func publishOnCommit[T any](tx *bbolt.Tx, broker *events.Broker[T], event T) {
+	tx.OnCommit(func() {
+		broker.Publish(event)
+	})
+}
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cchirag/ira/internal/enums"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// ExportVersion is the format version written into every SessionExport.
+const ExportVersion = 1
+
+var ErrUnsupportedExportVersion = errors.New("unsupported export version")
+
+// SessionExport is a self-contained, JSON-serializable copy of a session
+// tree. Windows are ordered by Index and panes top-to-bottom, left-to-right.
+type SessionExport struct {
+	Version int            `json:"version"`
+	Session SessionEntry   `json:"session"`
+	Windows []WindowExport `json:"windows"`
+}
+
+type WindowExport struct {
+	Window WindowEntry `json:"window"`
+	Panes  []PaneEntry `json:"panes"`
+}
+
+func exportSession(tx *bbolt.Tx, id uuid.UUID) (SessionExport, error) {
+	if tx == nil {
+		return SessionExport{}, ErrTxnNotFound
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return SessionExport{}, err
+	}
+
+	windows, err := GetWindows(tx, session.ID)
+	if err != nil {
+		return SessionExport{}, err
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Index < windows[j].Index
+	})
+
+	export := SessionExport{
+		Version: ExportVersion,
+		Session: session,
+		Windows: make([]WindowExport, 0, len(windows)),
+	}
+
+	for _, window := range windows {
+		panes, err := GetPanes(tx, session.ID, window.ID)
+		if err != nil {
+			return SessionExport{}, err
+		}
+
+		sort.SliceStable(panes, func(i, j int) bool {
+			if panes[i].Y != panes[j].Y {
+				return panes[i].Y < panes[j].Y
+			}
+			return panes[i].X < panes[j].X
+		})
+
+		export.Windows = append(export.Windows, WindowExport{
+			Window: window,
+			Panes:  panes,
+		})
+	}
+
+	return export, nil
+}
+
+// ExportSession returns the session tree as compact JSON.
+func ExportSession(tx *bbolt.Tx, id uuid.UUID) ([]byte, error) {
+	export, err := exportSession(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(export)
+}
+
+// ExportSessionIndent is like ExportSession but indents the output for
+// reading by hand, as json.MarshalIndent does.
+func ExportSessionIndent(tx *bbolt.Tx, id uuid.UUID, indent string) ([]byte, error) {
+	export, err := exportSession(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(export, "", indent)
+}
+
+// ImportSession recreates a session tree written by ExportSession. With
+// preserveIDs the session, its windows and panes keep the IDs from the export
+// and the import fails if any of them is already taken; otherwise every entry
+// gets a fresh ID. Names, indices, pane geometry, cwds, titles and order are
+// kept either way.
+func ImportSession(tx *bbolt.Tx, data []byte, preserveIDs bool) (SessionEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return SessionEntry{}, err
+	}
+
+	var export SessionExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return SessionEntry{}, err
+	}
+
+	return importSession(tx, export, preserveIDs)
+}
+
+func importSession(tx *bbolt.Tx, export SessionExport, preserveIDs bool) (SessionEntry, error) {
+	if export.Version != ExportVersion {
+		return SessionEntry{}, fmt.Errorf("%w: %d", ErrUnsupportedExportVersion, export.Version)
+	}
+
+	session, err := importSessionEntry(tx, export.Session, preserveIDs)
+	if err != nil {
+		return SessionEntry{}, err
+	}
+
+	for _, exported := range export.Windows {
+		window := exported.Window
+		window.SchemaVersion = CurrentSchemaVersion
+		window.SessionID = session.ID
+		// Panes are recreated unzoomed.
+		window.ZoomedPaneID = uuid.Nil
+
+		if !preserveIDs {
+			// Focus history refers to pane IDs that are about to change.
+			window.ID, window.FocusHistory = uuid.New(), nil
+		} else if taken, err := windowIDTaken(tx, window.ID); err != nil {
+			return SessionEntry{}, err
+		} else if taken {
+			return SessionEntry{}, fmt.Errorf("import window %s: %w", window.ID, ErrWindowAlreadyExists)
+		}
+
+		if exported.Window.ID == export.Session.ActiveWindowID {
+			session.ActiveWindowID = window.ID
+		}
+
+		if err := putWindow(tx, window); err != nil {
+			return SessionEntry{}, err
+		}
+
+		if err := reserveWindowSeq(tx, session.ID, window.Seq); err != nil {
+			return SessionEntry{}, err
+		}
+
+		if MaxPanesPerWindow > 0 && len(exported.Panes) > MaxPanesPerWindow {
+			return SessionEntry{}, fmt.Errorf("import window %s: %w", exported.Window.ID, ErrPaneLimitReached)
+		}
+
+		for _, exportedPane := range exported.Panes {
+			pane, err := importPaneEntry(exportedPane, session.ID, window.ID, preserveIDs)
+			if err != nil {
+				return SessionEntry{}, fmt.Errorf("import pane %s: %w", exportedPane.ID, err)
+			}
+
+			if err := putPane(tx, pane); err != nil {
+				return SessionEntry{}, err
+			}
+
+			publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneCreated, Pane: pane})
+		}
+	}
+
+	if err := putSession(tx, session); err != nil {
+		return SessionEntry{}, err
+	}
+
+	return session, nil
+}
+
+// windowIDTaken reports whether any session in the db has a window with id, or
+// a pane bucket is left under it. Window IDs key the pane buckets across all
+// sessions, so a preserved ID must be unique in the whole db, not just in the
+// session being imported.
+func windowIDTaken(tx *bbolt.Tx, id uuid.UUID) (bool, error) {
+	key := []byte(id.String())
+
+	if panes := tx.Bucket(PaneBucket); panes != nil && (panes.Bucket(key) != nil || panes.Get(key) != nil) {
+		return true, nil
+	}
+
+	windows := tx.Bucket(WindowBucket)
+	if windows == nil {
+		return false, nil
+	}
+
+	taken := false
+	err := windows.ForEachBucket(func(sessionKey []byte) error {
+		if windows.Bucket(sessionKey).Get(key) != nil {
+			taken = true
+		}
+		return nil
+	})
+
+	return taken, err
+}
+
+// importPaneEntry returns the pane to store for an exported one. Everything
+// but the IDs is kept as exported, including an empty cwd, Title and Order;
+// the cwd and title are still validated.
+func importPaneEntry(exported PaneEntry, sessionId, windowId uuid.UUID, preserveIDs bool) (PaneEntry, error) {
+	cwd, err := normalizeCwd(exported.Cwd)
+	if err != nil {
+		return PaneEntry{}, err
+	}
+
+	title, err := sanitizeText(exported.Title, MaxTitleLength)
+	if err != nil {
+		return PaneEntry{}, err
+	}
+
+	if preserveIDs && exported.ID == uuid.Nil {
+		return PaneEntry{}, ErrInvalidPaneID
+	}
+
+	pane := exported
+	pane.SchemaVersion = CurrentSchemaVersion
+	pane.SsessionID, pane.WindowID = sessionId, windowId
+	pane.Cwd, pane.Title = cwd, title
+	// The window is recreated unzoomed.
+	pane.Zoomed = false
+
+	if !preserveIDs {
+		pane.ID = uuid.New()
+	}
+
+	return pane, nil
+}
+
+// importSessionEntry writes the session record and its name lookup for
+// ImportSession. The imported session always starts out inactive.
+func importSessionEntry(tx *bbolt.Tx, exported SessionEntry, preserveIDs bool) (SessionEntry, error) {
+	if !preserveIDs {
+		session, err := NewSession(tx, exported.Name)
+		if err != nil {
+			return SessionEntry{}, err
+		}
+
+		session.Description = exported.Description
+		session.Cwd = exported.Cwd
+		session.Labels = exported.Labels
+		session.Options = exported.Options
+		session.DefaultWidth = exported.DefaultWidth
+		session.DefaultHeight = exported.DefaultHeight
+		return session, nil
+	}
+
+	name, err := validateName(exported.Name)
+	if err != nil {
+		return SessionEntry{}, err
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(SessionBucket)
+	if err != nil {
+		return SessionEntry{}, err
+	}
+
+	lookupBucket, err := bucket.CreateBucketIfNotExists(SessionLookupBucket)
+	if err != nil {
+		return SessionEntry{}, err
+	}
+
+	if bucket.Get([]byte(exported.ID.String())) != nil {
+		return SessionEntry{}, ErrSessionAlreadyExists
+	}
+
+	if _, exists, err := sessionWithNameExists(tx, name); err != nil {
+		return SessionEntry{}, err
+	} else if exists {
+		return SessionEntry{}, ErrSessionAlreadyExists
+	}
+
+	session := exported
+	session.SchemaVersion = CurrentSchemaVersion
+	session.Name = name
+	session.Status = enums.Inactive
+	session.ActiveWindowID = uuid.Nil
+	session.UpdatedAt = now()
+
+	if err := putSession(tx, session); err != nil {
+		return SessionEntry{}, err
+	}
+
+	if err := lookupBucket.Put([]byte(session.Name), []byte(session.ID.String())); err != nil {
+		return SessionEntry{}, err
+	}
+
+	if err := indexSessionCreated(bucket, session); err != nil {
+		return SessionEntry{}, err
+	}
+
+	invalidateNamesOnCommit(tx, session.Name)
+	publishOnCommit(tx, SessionEvents, SessionEvent{Type: SessionCreated, Session: session})
+
+	return session, nil
+}
@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestExportSessionIndent(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "export")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		compact, err := ExportSession(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(compact, []byte("\n")) {
+			t.Fatal("expected compact export to be on one line")
+		}
+
+		indented, err := ExportSessionIndent(tx, sessionID, "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(indented, []byte("\n  ")) {
+			t.Fatal("expected indented export to contain indented lines")
+		}
+
+		var fromCompact, fromIndented SessionExport
+		if err := json.Unmarshal(compact, &fromCompact); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(indented, &fromIndented); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fromCompact, fromIndented) {
+			t.Fatal("expected indented export to parse to the same structure")
+		}
+
+		if len(fromIndented.Windows) != 2 || len(fromIndented.Windows[0].Panes) != 2 {
+			t.Fatalf("unexpected export tree: %+v", fromIndented)
+		}
+
+		return nil
+	})
+}
+
+func TestImportSessionPreservesIDs(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "imported")
+
+	var data []byte
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		data, err = ExportSession(tx, sessionID)
+		return err
+	})
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := ImportSession(tx, data, true)
+		return err
+	})
+	if !errors.Is(err, ErrSessionAlreadyExists) {
+		t.Fatalf("expected ErrSessionAlreadyExists, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		return DeleteSession(tx, sessionID)
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := ImportSession(tx, data, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.ID != sessionID {
+			t.Fatalf("expected session id %s, got %s", sessionID, session.ID)
+		}
+
+		reexported, err := exportSession(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var original SessionExport
+		if err := json.Unmarshal(data, &original); err != nil {
+			t.Fatal(err)
+		}
+		for i, window := range original.Windows {
+			if reexported.Windows[i].Window.ID != window.Window.ID {
+				t.Fatalf("window %d: expected id %s, got %s", i, window.Window.ID, reexported.Windows[i].Window.ID)
+			}
+			for j, pane := range window.Panes {
+				if reexported.Windows[i].Panes[j].ID != pane.ID {
+					t.Fatalf("window %d pane %d: expected id %s, got %s", i, j, pane.ID, reexported.Windows[i].Panes[j].ID)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestImportSessionRejectsWindowIDFromAnotherSession(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "original")
+
+	var export SessionExport
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		export, err = exportSession(tx, sessionID)
+		return err
+	})
+
+	// A different session that reuses the original's window IDs.
+	export.Session.ID, export.Session.Name = uuid.New(), "impostor"
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := ImportSession(tx, data, true)
+		return err
+	})
+	if !errors.Is(err, ErrWindowAlreadyExists) {
+		t.Fatalf("expected ErrWindowAlreadyExists, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		panes, err := GetAllPanes(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		if len(panes) != 4 {
+			t.Fatalf("expected the original 4 panes to be untouched, got %d", len(panes))
+		}
+		return nil
+	})
+}
+
+// decorateTree gives every pane of a buildTree session a title and a reversed
+// Order, and clears the first pane's cwd while the session has one to inherit.
+func decorateTree(t *testing.T, db *bbolt.DB, sessionID uuid.UUID) {
+	t.Helper()
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := UpdateSessionCwd(tx, sessionID, "/srv"); err != nil {
+			return err
+		}
+
+		panes, err := GetAllPanes(tx, sessionID)
+		if err != nil {
+			return err
+		}
+
+		for i, pane := range panes {
+			pane.Title = fmt.Sprintf("pane %d", i)
+			pane.Order = 10 - pane.Order
+			pane.Cwd = "/tmp"
+			if i == 0 {
+				pane.Cwd = ""
+			}
+			if err := putPane(tx, pane); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// assertSameTree compares two exports field by field. Without sameIDs the
+// session, window and pane IDs may differ.
+func assertSameTree(t *testing.T, want, got SessionExport, sameIDs bool) {
+	t.Helper()
+
+	if len(got.Windows) != len(want.Windows) {
+		t.Fatalf("expected %d windows, got %d", len(want.Windows), len(got.Windows))
+	}
+
+	for i, exported := range want.Windows {
+		window := got.Windows[i].Window
+		if !sameIDs {
+			window.ID, window.SessionID = exported.Window.ID, exported.Window.SessionID
+		}
+		if !reflect.DeepEqual(window, exported.Window) {
+			t.Fatalf("window %d: expected %+v, got %+v", i, exported.Window, window)
+		}
+
+		if len(got.Windows[i].Panes) != len(exported.Panes) {
+			t.Fatalf("window %d: expected %d panes, got %d", i, len(exported.Panes), len(got.Windows[i].Panes))
+		}
+		for j, pane := range got.Windows[i].Panes {
+			if !sameIDs {
+				pane.ID, pane.SsessionID, pane.WindowID = exported.Panes[j].ID, exported.Panes[j].SsessionID, exported.Panes[j].WindowID
+			}
+			if !reflect.DeepEqual(pane, exported.Panes[j]) {
+				t.Fatalf("window %d pane %d: expected %+v, got %+v", i, j, exported.Panes[j], pane)
+			}
+		}
+	}
+}
+
+func TestImportSessionDuplicateKeepsPanes(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "original")
+	decorateTree(t, db, sessionID)
+
+	var want SessionExport
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		want, err = exportSession(tx, sessionID)
+		return err
+	})
+
+	duplicate := want
+	duplicate.Session.Name = "duplicate"
+	data, err := json.Marshal(duplicate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := ImportSession(tx, data, false)
+		if err != nil {
+			return err
+		}
+
+		got, err := exportSession(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		assertSameTree(t, want, got, false)
+		return nil
+	})
+}
+
+func TestImportSessionMoveKeepsPanes(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "moved")
+	decorateTree(t, db, sessionID)
+
+	var want SessionExport
+	var data []byte
+
+	// A move deletes the session and imports it back with its IDs.
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		if want, err = exportSession(tx, sessionID); err != nil {
+			return err
+		}
+		if data, err = json.Marshal(want); err != nil {
+			return err
+		}
+		return DeleteSession(tx, sessionID)
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := ImportSession(tx, data, true); err != nil {
+			return err
+		}
+
+		got, err := exportSession(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		assertSameTree(t, want, got, true)
+		return nil
+	})
+}
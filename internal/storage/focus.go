@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// MaxFocusHistory caps how many panes a window's FocusHistory remembers.
+var MaxFocusHistory = 8
+
+// SetActivePane records a pane as the focused one in its window. A pane
+// already in the history moves to the end instead of being repeated.
+func SetActivePane(tx *bbolt.Tx, sessionId, windowId, paneId uuid.UUID) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	if _, err := GetPane(tx, sessionId, window.ID, paneId); err != nil {
+		return err
+	}
+
+	history := append(withoutPane(window.FocusHistory, paneId), paneId)
+	if len(history) > MaxFocusHistory {
+		history = history[len(history)-MaxFocusHistory:]
+	}
+
+	window.FocusHistory, window.UpdatedAt = history, now()
+
+	return putWindow(tx, window)
+}
+
+// GetLastFocusedPane returns the pane focused before the current one, for
+// toggling back to it. It returns ErrPaneNotFound until two different panes
+// have been focused.
+func GetLastFocusedPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (uuid.UUID, error) {
+	if tx == nil {
+		return uuid.Nil, ErrTxnNotFound
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if len(window.FocusHistory) < 2 {
+		return uuid.Nil, ErrPaneNotFound
+	}
+
+	return window.FocusHistory[len(window.FocusHistory)-2], nil
+}
+
+// withoutPane returns history with paneId removed. history is not modified.
+func withoutPane(history []uuid.UUID, paneId uuid.UUID) []uuid.UUID {
+	return slices.DeleteFunc(slices.Clone(history), func(id uuid.UUID) bool {
+		return id == paneId
+	})
+}
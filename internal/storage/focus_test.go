@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestPaneFocusHistory(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var panes []uuid.UUID
+		for i := range 3 {
+			pane, err := NewPane(tx, sessionID, windowID, 80, 24, int32(i)*80, 0, "/tmp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			panes = append(panes, pane.ID)
+		}
+
+		if _, err := GetLastFocusedPane(tx, sessionID, windowID); !errors.Is(err, ErrPaneNotFound) {
+			t.Fatalf("expected ErrPaneNotFound before any focus, got %v", err)
+		}
+
+		expectLast := func(want uuid.UUID) {
+			t.Helper()
+			last, err := GetLastFocusedPane(tx, sessionID, windowID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if last != want {
+				t.Fatalf("expected last focused %s, got %s", want, last)
+			}
+		}
+
+		for _, id := range []uuid.UUID{panes[0], panes[1]} {
+			if err := SetActivePane(tx, sessionID, windowID, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expectLast(panes[0])
+
+		// Toggling swaps current and last.
+		if err := SetActivePane(tx, sessionID, windowID, panes[0]); err != nil {
+			t.Fatal(err)
+		}
+		expectLast(panes[1])
+
+		if err := SetActivePane(tx, sessionID, windowID, panes[2]); err != nil {
+			t.Fatal(err)
+		}
+		expectLast(panes[0])
+
+		// Deleting the last focused pane falls back to the one before it.
+		if err := DeletePane(tx, sessionID, windowID, panes[0]); err != nil {
+			t.Fatal(err)
+		}
+		expectLast(panes[1])
+
+		window, err := GetWindow(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(window.FocusHistory) != 2 {
+			t.Fatalf("expected 2 panes in history, got %v", window.FocusHistory)
+		}
+
+		if err := SetActivePane(tx, sessionID, windowID, uuid.New()); !errors.Is(err, ErrPaneNotFound) {
+			t.Fatalf("expected ErrPaneNotFound for unknown pane, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestPaneFocusHistoryIsBounded(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for i := range MaxFocusHistory + 3 {
+			pane, err := NewPane(tx, sessionID, windowID, 1, 1, int32(i), 0, "/tmp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := SetActivePane(tx, sessionID, windowID, pane.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		window, err := GetWindow(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(window.FocusHistory) != MaxFocusHistory {
+			t.Fatalf("expected history capped at %d, got %d", MaxFocusHistory, len(window.FocusHistory))
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,7 @@
+//go:build !iradebug
+
+package storage
+
+// debugInvariants enables the extra consistency checks run after index
+// rewrites. Build with -tags iradebug to turn them on.
+const debugInvariants = false
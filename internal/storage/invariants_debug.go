@@ -0,0 +1,5 @@
+//go:build iradebug
+
+package storage
+
+const debugInvariants = true
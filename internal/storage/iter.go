@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"iter"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// SessionsIter yields sessions one at a time instead of loading them all into
+// a slice. Sub-buckets such as the name lookup are skipped, and like
+// GetSessions a fresh DB yields nothing. A decode failure is yielded once as
+// the error and ends the iteration. The sequence is only valid while tx is
+// open.
+func SessionsIter(tx *bbolt.Tx) iter.Seq2[SessionEntry, error] {
+	return func(yield func(SessionEntry, error) bool) {
+		if tx == nil {
+			yield(SessionEntry{}, ErrTxnNotFound)
+			return
+		}
+
+		bucket := tx.Bucket(SessionBucket)
+		if bucket == nil {
+			return
+		}
+
+		bucketEntries(bucket, SessionKind, yield)
+	}
+}
+
+// WindowsIter is the iterator counterpart of GetWindows.
+func WindowsIter(tx *bbolt.Tx, sessionId uuid.UUID) iter.Seq2[WindowEntry, error] {
+	return func(yield func(WindowEntry, error) bool) {
+		if tx == nil {
+			yield(WindowEntry{}, ErrTxnNotFound)
+			return
+		}
+
+		session, err := GetSession(tx, sessionId)
+		if err != nil {
+			yield(WindowEntry{}, err)
+			return
+		}
+
+		bucket := tx.Bucket(WindowBucket)
+		if bucket == nil {
+			return
+		}
+
+		key := []byte(session.ID.String())
+
+		sessionBucket := bucket.Bucket(key)
+		if sessionBucket == nil {
+			// As in GetWindows, only a missing bucket means no windows.
+			if bucket.Get(key) != nil {
+				yield(WindowEntry{}, ErrWindowSessionBucketNotFound)
+			}
+			return
+		}
+
+		bucketEntries(sessionBucket, WindowKind, yield)
+	}
+}
+
+// PanesIter is the iterator counterpart of GetPanes.
+func PanesIter(tx *bbolt.Tx, sessionId, windowId uuid.UUID) iter.Seq2[PaneEntry, error] {
+	return func(yield func(PaneEntry, error) bool) {
+		if tx == nil {
+			yield(PaneEntry{}, ErrTxnNotFound)
+			return
+		}
+
+		window, err := GetWindow(tx, sessionId, windowId)
+		if err != nil {
+			yield(PaneEntry{}, err)
+			return
+		}
+
+		bucket := tx.Bucket(PaneBucket)
+		if bucket == nil {
+			return
+		}
+
+		key := []byte(window.ID.String())
+
+		windowBucket := bucket.Bucket(key)
+		if windowBucket == nil {
+			// As in GetPanes, only a missing bucket means no panes.
+			if bucket.Get(key) != nil {
+				yield(PaneEntry{}, ErrPaneWindowBucketNotFound)
+			}
+			return
+		}
+
+		bucketEntries(windowBucket, PaneKind, yield)
+	}
+}
+
+// bucketEntries decodes each value in bucket and hands it to yield until
+// yield returns false or an entry fails to decode. Nested buckets are skipped.
+func bucketEntries[T any](bucket *bbolt.Bucket, kind EntryKind, yield func(T, error) bool) {
+	cursor := bucket.Cursor()
+
+	for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		if v == nil {
+			continue
+		}
+
+		var entry T
+		if err := unmarshalEntry(bucket.Tx(), kind, v, &entry); err != nil {
+			var zero T
+			yield(zero, err)
+			return
+		}
+
+		if !yield(entry, nil) {
+			return
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestSessionsIterBreakEarly(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for _, name := range []string{"iter-a", "iter-b", "iter-c"} {
+			if _, err := NewSession(tx, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		seen := 0
+		for session, err := range SessionsIter(tx) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.ID == uuid.Nil {
+				t.Fatalf("expected a decoded session, got %+v", session)
+			}
+			seen++
+			break
+		}
+		if seen != 1 {
+			t.Fatalf("expected the loop to stop after 1 session, got %d", seen)
+		}
+
+		// A full pass yields only sessions, never the index sub-buckets.
+		total := 0
+		for _, err := range SessionsIter(tx) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			total++
+		}
+		if total != 3 {
+			t.Fatalf("expected 3 sessions, got %d", total)
+		}
+
+		return nil
+	})
+}
+
+func TestSessionsIterCorruptEntry(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := NewSession(tx, "iter-good"); err != nil {
+			return err
+		}
+		return tx.Bucket(SessionBucket).Put([]byte(uuid.New().String()), []byte("garbage"))
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var failures int
+		for _, err := range SessionsIter(tx) {
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, ErrCorruptEntry) {
+				t.Fatalf("expected ErrCorruptEntry, got %v", err)
+			}
+			failures++
+		}
+		if failures != 1 {
+			t.Fatalf("expected exactly 1 error, got %d", failures)
+		}
+		return nil
+	})
+}
+
+func TestPanesIter(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "iter-tree")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		windows := 0
+		for window, err := range WindowsIter(tx, sessionID) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			windows++
+
+			panes := 0
+			for _, err := range PanesIter(tx, sessionID, window.ID) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				panes++
+			}
+			if panes != 2 {
+				t.Fatalf("expected 2 panes in window %d, got %d", window.Index, panes)
+			}
+		}
+		if windows != 2 {
+			t.Fatalf("expected 2 windows, got %d", windows)
+		}
+		return nil
+	})
+}
+
+func TestSessionReadersOnEmptyDB(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		for _, err := range SessionsIter(tx) {
+			t.Fatalf("expected SessionsIter to yield nothing, got %v", err)
+		}
+
+		sorted, err := GetSessionsSorted(tx, ByCreatedAt)
+		if err != nil || len(sorted) != 0 {
+			t.Fatalf("expected no sorted sessions, got %v, %v", sorted, err)
+		}
+
+		names, err := GetSessionNames(tx, "")
+		if err != nil || names == nil || len(names) != 0 {
+			t.Fatalf("expected an empty name list, got %v, %v", names, err)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIterEmptyChildren(t *testing.T) {
+	db := openTestDB(t)
+
+	var session SessionEntry
+	var window WindowEntry
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		if session, err = NewSession(tx, "iter-empty"); err != nil {
+			return err
+		}
+
+		// Checked before any window exists, so there is no WINDOW bucket yet.
+		for _, err := range WindowsIter(tx, session.ID) {
+			t.Fatalf("expected WindowsIter to yield nothing, got %v", err)
+		}
+
+		window, err = NewWindow(tx, session.ID)
+		return err
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		other, err := NewSession(tx, "iter-other")
+		if err != nil {
+			return err
+		}
+
+		for _, err := range WindowsIter(tx, other.ID) {
+			t.Fatalf("expected WindowsIter to yield nothing for a session without windows, got %v", err)
+		}
+		for _, err := range PanesIter(tx, session.ID, window.ID) {
+			t.Fatalf("expected PanesIter to yield nothing for a window without panes, got %v", err)
+		}
+
+		return nil
+	})
+}
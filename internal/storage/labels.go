@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	ErrEmptyLabelKey   = errors.New("empty label key")
+	ErrInvalidLabelKey = errors.New("invalid label key: must start with a letter or _ and contain only letters, digits, _, -, . (max 64)")
+)
+
+var labelKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]{0,63}$`)
+
+func validateLabelKey(key string) error {
+	if key == "" {
+		return ErrEmptyLabelKey
+	}
+
+	if !labelKeyPattern.MatchString(key) {
+		return ErrInvalidLabelKey
+	}
+
+	return nil
+}
+
+func SetSessionLabel(tx *bbolt.Tx, id uuid.UUID, key, value string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	if err := validateLabelKey(key); err != nil {
+		return err
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if session.Labels == nil {
+		session.Labels = make(map[string]string)
+	}
+	session.Labels[key], session.UpdatedAt = value, now()
+
+	return putSession(tx, session)
+}
+
+func DeleteSessionLabel(tx *bbolt.Tx, id uuid.UUID, key string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	if err := validateLabelKey(key); err != nil {
+		return err
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := session.Labels[key]; !ok {
+		return nil
+	}
+
+	delete(session.Labels, key)
+	session.UpdatedAt = now()
+
+	return putSession(tx, session)
+}
+
+// GetSessionsByLabel returns the sessions whose label key is set to value.
+func GetSessionsByLabel(tx *bbolt.Tx, key, value string) ([]SessionEntry, error) {
+	if err := validateLabelKey(key); err != nil {
+		return nil, err
+	}
+
+	sessions, err := GetSessions(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]SessionEntry, 0)
+
+	for _, session := range sessions {
+		if v, ok := session.Labels[key]; ok && v == value {
+			matches = append(matches, session)
+		}
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestSessionLabels(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		foo, err := NewSession(tx, "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		bar, err := NewSession(tx, "bar")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SetSessionLabel(tx, foo.ID, "project", "foo"); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetSessionLabel(tx, foo.ID, "team", "infra"); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetSessionLabel(tx, bar.ID, "team", "infra"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SetSessionLabel(tx, foo.ID, "", "x"); !errors.Is(err, ErrEmptyLabelKey) {
+			t.Fatalf("expected ErrEmptyLabelKey, got %v", err)
+		}
+		if err := SetSessionLabel(tx, foo.ID, "9lives", "x"); !errors.Is(err, ErrInvalidLabelKey) {
+			t.Fatalf("expected ErrInvalidLabelKey, got %v", err)
+		}
+
+		infra, err := GetSessionsByLabel(tx, "team", "infra")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infra) != 2 {
+			t.Fatalf("expected 2 infra sessions, got %d", len(infra))
+		}
+
+		projects, err := GetSessionsByLabel(tx, "project", "foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(projects) != 1 || projects[0].ID != foo.ID {
+			t.Fatalf("expected only foo, got %v", projects)
+		}
+
+		if err := DeleteSessionLabel(tx, foo.ID, "team"); err != nil {
+			t.Fatal(err)
+		}
+
+		infra, err = GetSessionsByLabel(tx, "team", "infra")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infra) != 1 || infra[0].ID != bar.ID {
+			t.Fatalf("expected only bar after delete, got %v", infra)
+		}
+
+		return nil
+	})
+}
+
+func TestLargeLabelMapIsRejected(t *testing.T) {
+	db := openTestDB(t)
+
+	var session SessionEntry
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		session, err = NewSession(tx, "bloated")
+		return err
+	})
+
+	value := strings.Repeat("x", 1024)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for i := range 128 {
+			if err := SetSessionLabel(tx, session.ID, fmt.Sprintf("key%d", i), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatalf("expected ErrEntryTooLarge, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		stored, err := GetSession(tx, session.ID)
+		if err != nil {
+			return err
+		}
+		if len(stored.Labels) != 0 {
+			t.Fatalf("expected the failed txn to leave no labels, got %d", len(stored.Labels))
+		}
+		return nil
+	})
+}
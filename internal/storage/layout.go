@@ -0,0 +1,161 @@
+package storage
+
+// Layouts are named pane arrangements that can be applied to any window.
+//
+// BoltDB layout:
+//
+//   LAYOUT (bucket)
+//     └── <layout-name> → JSON(LayoutEntry)
+//
+// Notes:
+//   - Layout names follow the same rules as session names.
+//   - Pane IDs are not kept in a saved layout; only geometry matters.
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	ErrLayoutNotFound       = errors.New("layout not found")
+	ErrLayoutBucketNotFound = errors.New("layout bucket not found")
+)
+
+// LayoutBucket holds saved layouts keyed by name.
+var LayoutBucket = []byte("LAYOUT")
+
+type LayoutEntry struct {
+	Name      string         `json:"name"`
+	Panes     []PaneGeometry `json:"panes"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+func SaveLayout(tx *bbolt.Tx, name string, layout []PaneGeometry) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	name, err := validateName(name)
+	if err != nil {
+		return err
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(LayoutBucket)
+	if err != nil {
+		return err
+	}
+
+	entry := LayoutEntry{
+		Name:      name,
+		Panes:     make([]PaneGeometry, 0, len(layout)),
+		CreatedAt: now(),
+		UpdatedAt: now(),
+	}
+
+	if old := bucket.Get([]byte(name)); old != nil {
+		var existing LayoutEntry
+		if err := json.Unmarshal(old, &existing); err != nil {
+			return err
+		}
+		entry.CreatedAt = existing.CreatedAt
+	}
+
+	for _, geometry := range layout {
+		geometry.ID = uuid.Nil
+		entry.Panes = append(entry.Panes, geometry)
+	}
+
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(entry.Name), bytes)
+}
+
+func GetLayout(tx *bbolt.Tx, name string) (LayoutEntry, error) {
+	if tx == nil {
+		return LayoutEntry{}, ErrTxnNotFound
+	}
+
+	name, err := validateName(name)
+	if err != nil {
+		return LayoutEntry{}, err
+	}
+
+	bucket := tx.Bucket(LayoutBucket)
+	if bucket == nil {
+		return LayoutEntry{}, ErrLayoutBucketNotFound
+	}
+
+	bytes := bucket.Get([]byte(name))
+	if bytes == nil {
+		return LayoutEntry{}, ErrLayoutNotFound
+	}
+
+	var layout LayoutEntry
+	if err := json.Unmarshal(bytes, &layout); err != nil {
+		return LayoutEntry{}, err
+	}
+
+	return layout, nil
+}
+
+// ListLayouts returns every saved layout, or an empty slice if none were saved.
+func ListLayouts(tx *bbolt.Tx) ([]LayoutEntry, error) {
+	if tx == nil {
+		return nil, ErrTxnNotFound
+	}
+
+	bucket := tx.Bucket(LayoutBucket)
+	if bucket == nil {
+		return make([]LayoutEntry, 0), nil
+	}
+
+	layouts := make([]LayoutEntry, 0, bucket.Stats().KeyN)
+
+	if err := bucket.ForEach(func(k, v []byte) error {
+		var layout LayoutEntry
+		if err := json.Unmarshal(v, &layout); err != nil {
+			return err
+		}
+
+		layouts = append(layouts, layout)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return layouts, nil
+}
+
+// ApplyLayoutToWindow creates one pane per geometry of the named layout in the
+// given window. The new panes inherit their cwd.
+func ApplyLayoutToWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID, name string) ([]PaneEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return nil, err
+	}
+
+	layout, err := GetLayout(tx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	panes := make([]PaneEntry, 0, len(layout.Panes))
+
+	for _, geometry := range layout.Panes {
+		pane, err := NewPane(tx, sessionId, windowId, geometry.Width, geometry.Height, geometry.X, geometry.Y, "")
+		if err != nil {
+			return nil, err
+		}
+
+		panes = append(panes, pane)
+	}
+
+	return panes, nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestLayoutRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	split := []PaneGeometry{
+		{X: 0, Y: 0, Width: 80, Height: 48},
+		{X: 80, Y: 0, Width: 80, Height: 48},
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := SaveLayout(tx, "side-by-side", split); err != nil {
+			t.Fatal(err)
+		}
+		if err := SaveLayout(tx, "single", split[:1]); err != nil {
+			t.Fatal(err)
+		}
+		if err := SaveLayout(tx, "bad name!", split); !errors.Is(err, ErrInvalidSessionName) {
+			t.Fatalf("expected ErrInvalidSessionName, got %v", err)
+		}
+
+		return nil
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		layouts, err := ListLayouts(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(layouts) != 2 {
+			t.Fatalf("expected 2 layouts, got %d", len(layouts))
+		}
+
+		panes, err := ApplyLayoutToWindow(tx, sessionID, windowID, "side-by-side")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(panes) != len(split) {
+			t.Fatalf("expected %d panes, got %d", len(split), len(panes))
+		}
+
+		for i, pane := range panes {
+			if pane.X != split[i].X || pane.Y != split[i].Y || pane.Width != split[i].Width || pane.Height != split[i].Height {
+				t.Fatalf("pane %d does not match layout: %+v", i, pane)
+			}
+		}
+
+		if _, err := GetLayout(tx, "missing"); !errors.Is(err, ErrLayoutNotFound) {
+			t.Fatalf("expected ErrLayoutNotFound, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestListLayoutsEmptyDB(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		layouts, err := ListLayouts(tx)
+		if err != nil {
+			return err
+		}
+		if layouts == nil || len(layouts) != 0 {
+			t.Fatalf("expected an empty slice, got %v", layouts)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+// The __meta__ bucket records DB-wide state. RunMigrations keeps its
+// schema_version key in step with the registered DB migrations:
+//
+//   __meta__ (bucket)
+//     └── schema_version → decimal version
+//
+// DB migrations are one-time rewrites of stored data. They complement entry
+// migrations (RegisterMigration), which upgrade single entries on read.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// MetaBucket holds DB-wide metadata such as the schema version.
+var MetaBucket = []byte("__meta__")
+
+var schemaVersionKeyName = []byte("schema_version")
+
+// ErrSchemaTooNew is returned by RunMigrations for a DB stamped by a newer
+// build than this one.
+var ErrSchemaTooNew = errors.New("db schema is newer than this build supports")
+
+type dbMigration func(tx *bbolt.Tx) error
+
+var (
+	dbMigrationsMu sync.Mutex
+	dbMigrations   []dbMigration
+)
+
+// RegisterDBMigration registers fn as the migration that brings the DB to
+// version. Versions start at 1 and must be registered in order; anything else
+// panics.
+func RegisterDBMigration(version int, fn func(tx *bbolt.Tx) error) {
+	dbMigrationsMu.Lock()
+	defer dbMigrationsMu.Unlock()
+
+	if version != len(dbMigrations)+1 {
+		panic(fmt.Sprintf("storage: db migration %d registered out of order, expected %d", version, len(dbMigrations)+1))
+	}
+
+	dbMigrations = append(dbMigrations, fn)
+}
+
+// DBSchemaVersion is the version RunMigrations brings a DB to.
+func DBSchemaVersion() int {
+	dbMigrationsMu.Lock()
+	defer dbMigrationsMu.Unlock()
+
+	return len(dbMigrations)
+}
+
+// GetDBSchemaVersion returns the version stamped in the DB, or 0 if it has
+// never been migrated.
+func GetDBSchemaVersion(tx *bbolt.Tx) (int, error) {
+	if tx == nil {
+		return 0, ErrTxnNotFound
+	}
+
+	meta := tx.Bucket(MetaBucket)
+	if meta == nil {
+		return 0, nil
+	}
+
+	raw := meta.Get(schemaVersionKeyName)
+	if raw == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, fmt.Errorf("%w: schema version %q", ErrCorruptEntry, raw)
+	}
+
+	return version, nil
+}
+
+// RunMigrations applies every registered DB migration newer than the DB's
+// stamped version, in order, and stamps the new version, all in one
+// transaction. A fresh DB with no data is stamped without running anything.
+// Running it again is a no-op. A DB stamped with a newer version than
+// DBSchemaVersion is left alone and fails with ErrSchemaTooNew.
+func RunMigrations(db *bbolt.DB) error {
+	dbMigrationsMu.Lock()
+	migrations := append([]dbMigration(nil), dbMigrations...)
+	dbMigrationsMu.Unlock()
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		hookCommit(tx)
+
+		version, err := GetDBSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+
+		if version > len(migrations) {
+			return fmt.Errorf("%w: db is at version %d, this build knows up to %d", ErrSchemaTooNew, version, len(migrations))
+		}
+
+		fresh := tx.Bucket(MetaBucket) == nil &&
+			tx.Bucket(SessionBucket) == nil &&
+			tx.Bucket(WindowBucket) == nil &&
+			tx.Bucket(PaneBucket) == nil
+
+		if !fresh {
+			for ; version < len(migrations); version++ {
+				if err := migrations[version](tx); err != nil {
+					return fmt.Errorf("db migration %d: %w", version+1, err)
+				}
+			}
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(MetaBucket)
+		if err != nil {
+			return err
+		}
+
+		return meta.Put(schemaVersionKeyName, []byte(strconv.Itoa(len(migrations))))
+	})
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func withDBMigrations(t *testing.T, fns ...func(tx *bbolt.Tx) error) {
+	t.Helper()
+
+	original := dbMigrations
+	dbMigrations = nil
+	t.Cleanup(func() { dbMigrations = original })
+
+	for i, fn := range fns {
+		RegisterDBMigration(i+1, fn)
+	}
+}
+
+func TestRunMigrationsIsIdempotent(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		_, err := NewSession(tx, "existing")
+		return err
+	})
+
+	applied := 0
+	withDBMigrations(t, func(tx *bbolt.Tx) error {
+		applied++
+		return nil
+	})
+
+	for range 2 {
+		if err := RunMigrations(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if applied != 1 {
+		t.Fatalf("expected migration to apply once, applied %d times", applied)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		version, err := GetDBSchemaVersion(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != 1 {
+			t.Fatalf("expected schema version 1, got %d", version)
+		}
+		return nil
+	})
+}
+
+func TestRunMigrationsStampsFreshDB(t *testing.T) {
+	db := openTestDB(t)
+
+	withDBMigrations(t, func(tx *bbolt.Tx) error {
+		t.Fatal("expected no migration to run on a fresh db")
+		return nil
+	})
+
+	if err := RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		version, err := GetDBSchemaVersion(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != DBSchemaVersion() {
+			t.Fatalf("expected fresh db stamped at %d, got %d", DBSchemaVersion(), version)
+		}
+		return nil
+	})
+}
+
+func TestRunMigrationsRejectsNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	withDBMigrations(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(MetaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKeyName, []byte("3"))
+	})
+
+	if err := RunMigrations(db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("expected ErrSchemaTooNew, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		version, err := GetDBSchemaVersion(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != 3 {
+			t.Fatalf("expected the stamp to stay at 3, got %d", version)
+		}
+		return nil
+	})
+}
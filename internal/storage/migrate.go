@@ -0,0 +1,145 @@
+package storage
+
+// Every stored entry carries a SchemaVersion. Entries written by an older
+// build are upgraded in memory when read, by running the registered migration
+// steps from their version up to CurrentSchemaVersion. Versions without a
+// registered step are taken to need no changes. Upgraded entries are written
+// back with the current version the next time they are updated.
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.etcd.io/bbolt"
+)
+
+// ErrCorruptEntry wraps the decode error of a stored entry that is not valid
+// JSON for its kind, so callers can tell it apart from a missing entry.
+var ErrCorruptEntry = errors.New("corrupt entry")
+
+// ErrEntryTooLarge is returned instead of writing a session, window or pane
+// whose encoded form is larger than MaxEntryBytes.
+var ErrEntryTooLarge = errors.New("entry too large")
+
+// MaxEntryBytes caps the encoded size of a single session, window or pane, so
+// a pathological value cannot bloat the db and slow every scan. Zero or less
+// disables the check.
+var MaxEntryBytes = 64 << 10
+
+// CurrentSchemaVersion is stamped on every entry written by this build.
+// Entries that predate versioning decode as version 0.
+const CurrentSchemaVersion = 1
+
+type EntryKind string
+
+const (
+	SessionKind EntryKind = "session"
+	WindowKind  EntryKind = "window"
+	PaneKind    EntryKind = "pane"
+)
+
+// Migration upgrades one decoded JSON entry in place.
+type Migration func(kind EntryKind, entry map[string]any) error
+
+type migrationStep struct {
+	to int
+	fn Migration
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = map[int]migrationStep{}
+)
+
+// RegisterMigration registers fn to upgrade entries from version from to
+// version to. It panics if to is not greater than from, or if a migration from
+// that version is already registered.
+func RegisterMigration(from, to int, fn Migration) {
+	if to <= from {
+		panic(fmt.Sprintf("storage: invalid migration %d -> %d", from, to))
+	}
+
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	if _, ok := migrations[from]; ok {
+		panic(fmt.Sprintf("storage: migration from version %d already registered", from))
+	}
+
+	migrations[from] = migrationStep{to: to, fn: fn}
+}
+
+func migrate(codec Codec, kind EntryKind, version int, data []byte) ([]byte, error) {
+	var entry map[string]any
+	if err := codec.Unmarshal(data, &entry); err != nil {
+		return nil, corruptEntry(kind, err)
+	}
+
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+
+	for version < CurrentSchemaVersion {
+		step, ok := migrations[version]
+		if !ok {
+			version++
+			continue
+		}
+
+		if err := step.fn(kind, entry); err != nil {
+			return nil, fmt.Errorf("migrate %s from version %d: %w", kind, version, err)
+		}
+		version = step.to
+	}
+
+	entry["schemaVersion"] = CurrentSchemaVersion
+
+	return codec.Marshal(entry)
+}
+
+// marshalEntry encodes a session, window or pane for storage with the codec of
+// tx's DB, refusing with ErrEntryTooLarge once it exceeds MaxEntryBytes.
+func marshalEntry(tx *bbolt.Tx, kind EntryKind, v any) ([]byte, error) {
+	data, err := codecFor(tx).Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if MaxEntryBytes > 0 && len(data) > MaxEntryBytes {
+		return nil, fmt.Errorf("%w: %s is %d bytes, the limit is %d", ErrEntryTooLarge, kind, len(data), MaxEntryBytes)
+	}
+
+	return data, nil
+}
+
+// unmarshalEntry decodes a stored session, window or pane, upgrading it first
+// if it was written with an older schema version.
+func unmarshalEntry(tx *bbolt.Tx, kind EntryKind, data []byte, v any) error {
+	codec := codecFor(tx)
+
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+
+	if err := codec.Unmarshal(data, &header); err != nil {
+		return corruptEntry(kind, err)
+	}
+
+	if header.SchemaVersion < CurrentSchemaVersion {
+		migrated, err := migrate(codec, kind, header.SchemaVersion, data)
+		if err != nil {
+			return err
+		}
+		data = migrated
+	}
+
+	if err := codec.Unmarshal(data, v); err != nil {
+		return corruptEntry(kind, err)
+	}
+
+	return nil
+}
+
+func corruptEntry(kind EntryKind, err error) error {
+	return fmt.Errorf("%w: %s: %w", ErrCorruptEntry, kind, err)
+}
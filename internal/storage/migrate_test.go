@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestMigrationRunsOnLegacyEntry(t *testing.T) {
+	db := openTestDB(t)
+
+	RegisterMigration(0, 1, func(kind EntryKind, entry map[string]any) error {
+		if kind == SessionKind {
+			entry["labels"] = map[string]any{"migrated": "v1"}
+		}
+		return nil
+	})
+	t.Cleanup(func() {
+		migrationsMu.Lock()
+		delete(migrations, 0)
+		migrationsMu.Unlock()
+	})
+
+	id := uuid.New()
+	legacy := `{"id":"` + id.String() + `","name":"legacy","status":1,"createdAt":"2024-01-01T00:00:00Z","updatedAt":"2024-01-01T00:00:00Z"}`
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(SessionBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(id.String()), []byte(legacy))
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := GetSession(tx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.SchemaVersion != CurrentSchemaVersion {
+			t.Fatalf("expected schema version %d, got %d", CurrentSchemaVersion, session.SchemaVersion)
+		}
+		if session.Labels["migrated"] != "v1" {
+			t.Fatalf("expected migration to fill labels, got %v", session.Labels)
+		}
+		if session.Name != "legacy" {
+			t.Fatalf("expected existing fields to survive, got %q", session.Name)
+		}
+
+		current, err := NewSession(tx, "current")
+		if err != nil {
+			t.Fatal(err)
+		}
+		current, err = GetSession(tx, current.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if current.Labels != nil {
+			t.Fatalf("expected migration not to run on current entries, got %v", current.Labels)
+		}
+
+		return nil
+	})
+}
+
+func TestCorruptEntry(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+	id := uuid.New()
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		return tx.Bucket(SessionBucket).Put([]byte(id.String()), []byte("{not json"))
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := GetSession(tx, id); !errors.Is(err, ErrCorruptEntry) {
+			t.Fatalf("expected ErrCorruptEntry, got %v", err)
+		}
+		if _, err := GetSession(tx, uuid.New()); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected missing session to stay ErrSessionNotFound, got %v", err)
+		}
+
+		windows := tx.Bucket(WindowBucket).Bucket([]byte(sessionID.String()))
+		if err := windows.Put([]byte(windowID.String()), []byte{0xff, 0x00}); err != nil {
+			return err
+		}
+		if _, err := GetWindow(tx, sessionID, windowID); !errors.Is(err, ErrCorruptEntry) {
+			t.Fatalf("expected ErrCorruptEntry for window, got %v", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// nameCaches holds the name cache of every Store that enabled one, keyed by
+// its *bbolt.DB, so the session functions can invalidate it from inside a tx.
+var nameCaches sync.Map
+
+// nameCache is an LRU of normalized session name → session ID.
+type nameCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+
+	// generation is bumped on every invalidation so a lookup that read the
+	// db before a rename committed does not put the old ID back afterwards.
+	generation uint64
+}
+
+type nameCacheItem struct {
+	name string
+	id   uuid.UUID
+}
+
+func newNameCache(size int) *nameCache {
+	return &nameCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *nameCache) get(name string) (uuid.UUID, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[name]
+	if !ok {
+		return uuid.Nil, c.generation, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(nameCacheItem).id, c.generation, true
+}
+
+func (c *nameCache) add(name string, id uuid.UUID, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if element, ok := c.items[name]; ok {
+		element.Value = nameCacheItem{name: name, id: id}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[name] = c.order.PushFront(nameCacheItem{name: name, id: id})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(nameCacheItem).name)
+	}
+}
+
+// remove drops names from the cache, or every entry when names is empty.
+func (c *nameCache) remove(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if len(names) == 0 {
+		c.order.Init()
+		clear(c.items)
+		return
+	}
+
+	for _, name := range names {
+		if element, ok := c.items[name]; ok {
+			c.order.Remove(element)
+			delete(c.items, name)
+		}
+	}
+}
+
+// invalidateNamesOnCommit drops names from the cache of tx's DB once tx
+// commits. No names drops everything. It does nothing if the DB has no cache.
+func invalidateNamesOnCommit(tx *bbolt.Tx, names ...string) {
+	cache, ok := nameCaches.Load(tx.DB())
+	if !ok {
+		return
+	}
+
+	tx.OnCommit(func() {
+		cache.(*nameCache).remove(names...)
+	})
+}
+
+// SetNameCacheSize turns on an in-memory LRU of up to size session names for
+// LookupSessionID, or turns it off when size is 0. It is off by default: a
+// cached lookup skips the transaction, so it is only as fresh as the last
+// create, rename or delete that went through a transaction on this DB.
+func (s *Store) SetNameCacheSize(size int) {
+	if size <= 0 {
+		nameCaches.Delete(s.db)
+		return
+	}
+
+	nameCaches.Store(s.db, newNameCache(size))
+}
+
+// LookupSessionID returns the ID of the session called name. With a name
+// cache enabled, a hit is answered without opening a transaction.
+func (s *Store) LookupSessionID(ctx context.Context, name string) (uuid.UUID, error) {
+	key, err := validateName(name)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	var cache *nameCache
+	var generation uint64
+	if cached, ok := nameCaches.Load(s.db); ok {
+		cache = cached.(*nameCache)
+
+		id, gen, hit := cache.get(key)
+		if hit {
+			return id, nil
+		}
+		generation = gen
+	}
+
+	var id uuid.UUID
+	err = s.ViewCtx(ctx, func(tx *bbolt.Tx) error {
+		session, err := GetSessionByName(tx, key)
+		id = session.ID
+		return err
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if cache != nil {
+		cache.add(key, id, generation)
+	}
+
+	return id, nil
+}
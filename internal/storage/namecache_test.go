@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestLookupSessionIDCache(t *testing.T) {
+	db := openTestDB(t)
+	store := NewStore(db)
+	store.SetNameCacheSize(8)
+	t.Cleanup(func() { nameCaches.Delete(db) })
+
+	// bbolt counts every read transaction it starts, which is all a
+	// LookupSessionID miss opens.
+	readTxs := func() int {
+		return db.Stats().TxN
+	}
+
+	var created SessionEntry
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		created, err = NewSession(tx, "cached")
+		return err
+	})
+
+	ctx := context.Background()
+
+	before := readTxs()
+	id, err := store.LookupSessionID(ctx, "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != created.ID {
+		t.Fatalf("expected %s, got %s", created.ID, id)
+	}
+	if got := readTxs() - before; got != 1 {
+		t.Fatalf("expected the first lookup to open 1 txn, got %d", got)
+	}
+
+	before = readTxs()
+	if id, err = store.LookupSessionID(ctx, "  cached "); err != nil {
+		t.Fatal(err)
+	}
+	if id != created.ID {
+		t.Fatalf("expected %s from the cache, got %s", created.ID, id)
+	}
+	if got := readTxs() - before; got != 0 {
+		t.Fatalf("expected a cached lookup to open no txn, got %d", got)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		return UpdateSessionName(tx, created.ID, "renamed")
+	})
+
+	if _, err := store.LookupSessionID(ctx, "cached"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound for the old name after a rename, got %v", err)
+	}
+	if id, err = store.LookupSessionID(ctx, "renamed"); err != nil || id != created.ID {
+		t.Fatalf("expected the new name to resolve to %s, got %s, %v", created.ID, id, err)
+	}
+}
+
+func TestNameCacheEvictsOldest(t *testing.T) {
+	cache := newNameCache(2)
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, generation, _ := cache.get(name)
+		cache.add(name, [16]byte{name[0]}, generation)
+	}
+
+	if _, _, ok := cache.get("a"); ok {
+		t.Fatal("expected the oldest name to be evicted")
+	}
+	for _, name := range []string{"b", "c"} {
+		if _, _, ok := cache.get(name); !ok {
+			t.Fatalf("expected %q to still be cached", name)
+		}
+	}
+}
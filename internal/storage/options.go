@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	ErrInvalidOptionKey   = errors.New("invalid option key: must be dotted lowercase identifiers, e.g. base-index or status.left")
+	ErrInvalidOptionValue = errors.New("invalid option value")
+)
+
+// BaseIndexOption is the session option holding the number the first window
+// is displayed as. Stored window indices always start at 0; only the number
+// shown to users shifts.
+const BaseIndexOption = "base-index"
+
+var optionKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*(\.[a-z0-9-]+)*$`)
+
+func validateOptionKey(key string) error {
+	if !optionKeyPattern.MatchString(key) {
+		return ErrInvalidOptionKey
+	}
+
+	return nil
+}
+
+// SetSessionOption stores a per-session option such as base-index. Options
+// are free-form strings; interpreting them is up to the caller.
+func SetSessionOption(tx *bbolt.Tx, id uuid.UUID, key, value string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	if err := validateOptionKey(key); err != nil {
+		return err
+	}
+
+	if key == BaseIndexOption {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("%w: %s must be a non-negative integer, got %q", ErrInvalidOptionValue, key, value)
+		}
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if session.Options == nil {
+		session.Options = make(map[string]string)
+	}
+	session.Options[key], session.UpdatedAt = value, now()
+
+	return putSession(tx, session)
+}
+
+// GetSessionOption returns the value of an option and whether it is set.
+func GetSessionOption(tx *bbolt.Tx, id uuid.UUID, key string) (string, bool, error) {
+	if tx == nil {
+		return "", false, ErrTxnNotFound
+	}
+
+	if err := validateOptionKey(key); err != nil {
+		return "", false, err
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := session.Options[key]
+
+	return value, ok, nil
+}
+
+// GetBaseIndex returns the session's base-index option, or 0 when unset.
+func GetBaseIndex(tx *bbolt.Tx, id uuid.UUID) (int, error) {
+	value, ok, err := GetSessionOption(tx, id, BaseIndexOption)
+	if err != nil || !ok {
+		return 0, err
+	}
+
+	base, err := strconv.Atoi(value)
+	if err != nil || base < 0 {
+		return 0, fmt.Errorf("%w: %s is %q", ErrInvalidOptionValue, BaseIndexOption, value)
+	}
+
+	return base, nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestSessionOptions(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "options")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := SetSessionOption(tx, session.ID, "base-index", "1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetSessionOption(tx, session.ID, "status.left", "ira"); err != nil {
+			t.Fatal(err)
+		}
+
+		value, ok, err := GetSessionOption(tx, session.ID, "base-index")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok || value != "1" {
+			t.Fatalf("expected base-index=1, got %q (set=%v)", value, ok)
+		}
+
+		if _, ok, err := GetSessionOption(tx, session.ID, "mouse"); err != nil || ok {
+			t.Fatalf("expected unset option, got set=%v err=%v", ok, err)
+		}
+
+		for _, key := range []string{"", "Mouse", "9lives", "status.", ".left", "a..b"} {
+			if err := SetSessionOption(tx, session.ID, key, "x"); !errors.Is(err, ErrInvalidOptionKey) {
+				t.Fatalf("expected ErrInvalidOptionKey for %q, got %v", key, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestBaseIndexOption(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "base")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if base, err := GetBaseIndex(tx, session.ID); err != nil || base != 0 {
+			t.Fatalf("expected default base index 0, got %d, %v", base, err)
+		}
+
+		for _, value := range []string{"one", "-1", ""} {
+			if err := SetSessionOption(tx, session.ID, BaseIndexOption, value); !errors.Is(err, ErrInvalidOptionValue) {
+				t.Fatalf("expected ErrInvalidOptionValue for %q, got %v", value, err)
+			}
+		}
+
+		if err := SetSessionOption(tx, session.ID, BaseIndexOption, "1"); err != nil {
+			t.Fatal(err)
+		}
+		if base, err := GetBaseIndex(tx, session.ID); err != nil || base != 1 {
+			t.Fatalf("expected base index 1, got %d, %v", base, err)
+		}
+
+		return nil
+	})
+}
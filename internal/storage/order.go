@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// SessionOrder selects the order GetSessionsSorted returns sessions in.
+type SessionOrder int
+
+const (
+	ByCreatedAt SessionOrder = iota
+	ByName
+)
+
+// SessionCreatedIndexBucket indexes sessions by creation time. It sits next to
+// the name lookup inside SESSION:
+//
+//	__session_by_created__ (bucket)
+//	  └── <created-at>/<session-id-uuid> → <session-id-uuid>
+//
+// The timestamp is fixed-width UTC so keys sort chronologically.
+var SessionCreatedIndexBucket = []byte("__session_by_created__")
+
+const createdIndexLayout = "2006-01-02T15:04:05.000000000Z"
+
+func createdIndexKey(session SessionEntry) []byte {
+	return []byte(session.CreatedAt.UTC().Format(createdIndexLayout) + "/" + session.ID.String())
+}
+
+// createdIndexForWrite returns the creation-time index of the session bucket,
+// creating it on first use and backfilling every session stored before it
+// existed.
+func createdIndexForWrite(bucket *bbolt.Bucket) (*bbolt.Bucket, error) {
+	if index := bucket.Bucket(SessionCreatedIndexBucket); index != nil {
+		return index, nil
+	}
+
+	index, err := bucket.CreateBucket(SessionCreatedIndexBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+
+		var session SessionEntry
+		if err := unmarshalEntry(bucket.Tx(), SessionKind, v, &session); err != nil {
+			return err
+		}
+
+		return index.Put(createdIndexKey(session), []byte(session.ID.String()))
+	}); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func indexSessionCreated(bucket *bbolt.Bucket, session SessionEntry) error {
+	index, err := createdIndexForWrite(bucket)
+	if err != nil {
+		return err
+	}
+
+	return index.Put(createdIndexKey(session), []byte(session.ID.String()))
+}
+
+func unindexSessionCreated(bucket *bbolt.Bucket, session SessionEntry) error {
+	index, err := createdIndexForWrite(bucket)
+	if err != nil {
+		return err
+	}
+
+	return index.Delete(createdIndexKey(session))
+}
+
+// GetSessionsSorted returns every session in the given order. Both orders are
+// read straight from an index bucket with a cursor rather than sorted in
+// memory; a DB whose creation-time index has not been built yet falls back to
+// sorting, and a fresh DB returns an empty slice.
+func GetSessionsSorted(tx *bbolt.Tx, order SessionOrder) ([]SessionEntry, error) {
+	if tx == nil {
+		return nil, ErrTxnNotFound
+	}
+
+	bucket := tx.Bucket(SessionBucket)
+	if bucket == nil {
+		return make([]SessionEntry, 0), nil
+	}
+
+	indexName := SessionCreatedIndexBucket
+	if order == ByName {
+		indexName = SessionLookupBucket
+	}
+
+	index := bucket.Bucket(indexName)
+	if index == nil {
+		sessions, err := GetSessions(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		sort.SliceStable(sessions, func(i, j int) bool {
+			if order == ByName {
+				return sessions[i].Name < sessions[j].Name
+			}
+			return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+		})
+
+		return sessions, nil
+	}
+
+	sessions := make([]SessionEntry, 0, index.Stats().KeyN)
+
+	if err := index.ForEach(func(k, v []byte) error {
+		id, err := uuid.ParseBytes(v)
+		if err != nil {
+			return err
+		}
+
+		session, err := GetSession(tx, id)
+		if err != nil {
+			return err
+		}
+
+		sessions = append(sessions, session)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestSessionCreatedIndex(t *testing.T) {
+	db := openTestDB(t)
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stubClock(t, day, day, day.AddDate(0, 0, 1), day.AddDate(0, 0, 1), day.AddDate(0, 0, 2))
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var created []SessionEntry
+		for _, name := range []string{"charlie", "alpha", "bravo"} {
+			session, err := NewSession(tx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			created = append(created, session)
+		}
+
+		sessions, err := GetSessionsSorted(tx, ByCreatedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSessionNames(t, sessions, "charlie", "alpha", "bravo")
+
+		if err := DeleteSession(tx, created[1].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		index := tx.Bucket(SessionBucket).Bucket(SessionCreatedIndexBucket)
+		if count := countKeys(index); count != 2 {
+			t.Fatalf("expected 2 index keys after delete, got %d", count)
+		}
+		if index.Get(createdIndexKey(created[1])) != nil {
+			t.Fatal("expected deleted session to be removed from the index")
+		}
+
+		sessions, err = GetSessionsSorted(tx, ByCreatedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSessionNames(t, sessions, "charlie", "bravo")
+
+		sessions, err = GetSessionsSorted(tx, ByName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSessionNames(t, sessions, "bravo", "charlie")
+
+		return nil
+	})
+}
+
+func assertSessionNames(t *testing.T, sessions []SessionEntry, names ...string) {
+	t.Helper()
+
+	if len(sessions) != len(names) {
+		t.Fatalf("expected %d sessions, got %d", len(names), len(sessions))
+	}
+	for i, name := range names {
+		if sessions[i].Name != name {
+			t.Fatalf("session %d: expected %q, got %q", i, name, sessions[i].Name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package storage
+
+import "go.etcd.io/bbolt"
+
+// FindOrphans lists WINDOW sub-buckets whose session no longer exists and
+// PANE sub-buckets whose window no longer exists. It only reads, so it can run
+// inside a View.
+func FindOrphans(tx *bbolt.Tx) (orphanWindows, orphanPanes []string, err error) {
+	if tx == nil {
+		return nil, nil, ErrTxnNotFound
+	}
+
+	orphanWindows, orphanPanes = []string{}, []string{}
+
+	sessions := tx.Bucket(SessionBucket)
+	liveWindows := make(map[string]struct{})
+
+	if windows := tx.Bucket(WindowBucket); windows != nil {
+		if err := windows.ForEach(func(k, v []byte) error {
+			if v != nil {
+				return nil
+			}
+
+			if sessions == nil || sessions.Get(k) == nil {
+				orphanWindows = append(orphanWindows, string(k))
+				return nil
+			}
+
+			return windows.Bucket(k).ForEach(func(windowId, _ []byte) error {
+				liveWindows[string(windowId)] = struct{}{}
+				return nil
+			})
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if panes := tx.Bucket(PaneBucket); panes != nil {
+		if err := panes.ForEach(func(k, v []byte) error {
+			if v != nil {
+				return nil
+			}
+
+			if _, ok := liveWindows[string(k)]; !ok {
+				orphanPanes = append(orphanPanes, string(k))
+			}
+
+			return nil
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return orphanWindows, orphanPanes, nil
+}
+
+// PruneOrphans deletes the sub-buckets reported by FindOrphans and returns how
+// many buckets were removed. Panes of an orphaned window are orphans too, so
+// they go with it.
+func PruneOrphans(tx *bbolt.Tx) (int, error) {
+	if err := writableTx(tx); err != nil {
+		return 0, err
+	}
+
+	orphanWindows, orphanPanes, err := FindOrphans(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+
+	if len(orphanWindows) > 0 {
+		windows := tx.Bucket(WindowBucket)
+
+		for _, key := range orphanWindows {
+			if err := windows.DeleteBucket([]byte(key)); err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+	}
+
+	if len(orphanPanes) > 0 {
+		panes := tx.Bucket(PaneBucket)
+
+		for _, key := range orphanPanes {
+			if err := panes.DeleteBucket([]byte(key)); err != nil {
+				return pruned, err
+			}
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestFindAndPruneOrphans(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	ghostSession, ghostWindow := uuid.New(), uuid.New()
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp"); err != nil {
+			t.Fatal(err)
+		}
+
+		windows := tx.Bucket(WindowBucket)
+		if _, err := windows.CreateBucket([]byte(ghostSession.String())); err != nil {
+			t.Fatal(err)
+		}
+
+		panes := tx.Bucket(PaneBucket)
+		if _, err := panes.CreateBucket([]byte(ghostWindow.String())); err != nil {
+			t.Fatal(err)
+		}
+
+		return nil
+	})
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		orphanWindows, orphanPanes, err := FindOrphans(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(orphanWindows) != 1 || orphanWindows[0] != ghostSession.String() {
+			t.Fatalf("unexpected orphan windows: %v", orphanWindows)
+		}
+		if len(orphanPanes) != 1 || orphanPanes[0] != ghostWindow.String() {
+			t.Fatalf("unexpected orphan panes: %v", orphanPanes)
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		pruned, err := PruneOrphans(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pruned != 2 {
+			t.Fatalf("expected 2 pruned buckets, got %d", pruned)
+		}
+
+		orphanWindows, orphanPanes, err := FindOrphans(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(orphanWindows) != 0 || len(orphanPanes) != 0 {
+			t.Fatalf("expected no orphans after prune, got %v %v", orphanWindows, orphanPanes)
+		}
+
+		panes, err := GetPanes(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(panes) != 1 {
+			t.Fatalf("expected live pane to survive, got %d", len(panes))
+		}
+
+		return nil
+	})
+}
@@ -1,8 +1,12 @@
 package storage
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,26 +17,94 @@ var (
 	ErrPaneNotFound             = errors.New("pane not found")
 	ErrPaneBucketNotFound       = errors.New("pane bucket not found")
 	ErrPaneWindowBucketNotFound = errors.New("pane window bucket not found")
+	ErrRelativeCwd              = errors.New("cwd must be an absolute path")
+	ErrCwdNotFound              = errors.New("cwd does not exist or is not a directory")
+	ErrPaneLimitReached         = errors.New("pane limit reached for window")
+	ErrInvalidPaneID            = errors.New("invalid pane id")
+	ErrPaneAlreadyExists        = errors.New("pane with the id already exists")
 )
 
-var paneBucketName = []byte("PANE")
+// PaneBucket holds one sub-bucket per window ID with its panes keyed by ID.
+var PaneBucket = []byte("PANE")
+
+// VerifyCwd makes pane writes check that the cwd exists on disk. By default
+// only syntactic checks are done, since the daemon may restore panes whose
+// directories are not (yet) present.
+var VerifyCwd = false
+
+// MaxPanesPerWindow caps how many panes a window may hold. Zero means
+// unlimited.
+var MaxPanesPerWindow = 0
 
 type PaneEntry struct {
-	ID         uuid.UUID `json:"id"`
-	SsessionID uuid.UUID `json:"sessionId"`
-	WindowID   uuid.UUID `json:"windowId"`
-	Width      int32     `json:"width"`
-	Height     int32     `json:"height"`
-	X          int32     `json:"x"`
-	Y          int32     `json:"y"`
-	Cwd        string    `json:"cwd"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
+	SchemaVersion int       `json:"schemaVersion"`
+	ID            uuid.UUID `json:"id"`
+	SsessionID    uuid.UUID `json:"sessionId"`
+	WindowID      uuid.UUID `json:"windowId"`
+	Width         int32     `json:"width"`
+	Height        int32     `json:"height"`
+	X             int32     `json:"x"`
+	Y             int32     `json:"y"`
+	Cwd           string    `json:"cwd"`
+	Title         string    `json:"title,omitempty"`
+	Zoomed        bool      `json:"zoomed,omitempty"`
+	Order         int       `json:"order"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
 }
 
+// normalizeCwd cleans a pane cwd and rejects relative paths. An empty cwd is
+// kept as is and means "inherit".
+func normalizeCwd(cwd string) (string, error) {
+	if cwd == "" {
+		return "", nil
+	}
+
+	if !filepath.IsAbs(cwd) {
+		return "", ErrRelativeCwd
+	}
+
+	cwd = filepath.Clean(cwd)
+
+	if VerifyCwd {
+		info, err := os.Stat(cwd)
+		if err != nil || !info.IsDir() {
+			return "", ErrCwdNotFound
+		}
+	}
+
+	return cwd, nil
+}
+
+// PaneGeometry is the position and size of a single pane within a layout.
+type PaneGeometry struct {
+	ID     uuid.UUID `json:"id"`
+	X      int32     `json:"x"`
+	Y      int32     `json:"y"`
+	Width  int32     `json:"width"`
+	Height int32     `json:"height"`
+}
+
+// NewPane creates a pane in a window. An empty cwd inherits the window's cwd,
+// or the session's when the window has none.
 func NewPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, width, height, x, y int32, cwd string) (PaneEntry, error) {
-	if tx == nil {
-		return PaneEntry{}, ErrTxnNotFound
+	return NewPaneWithID(tx, uuid.New(), sessionId, windowId, width, height, x, y, cwd)
+}
+
+// NewPaneWithID is like NewPane but stores the pane under the given id, so
+// imports can keep the IDs of the tree they came from.
+func NewPaneWithID(tx *bbolt.Tx, id uuid.UUID, sessionId, windowId uuid.UUID, width, height, x, y int32, cwd string) (PaneEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return PaneEntry{}, err
+	}
+
+	if id == uuid.Nil {
+		return PaneEntry{}, ErrInvalidPaneID
+	}
+
+	cwd, err := normalizeCwd(cwd)
+	if err != nil {
+		return PaneEntry{}, err
 	}
 
 	session, err := GetSession(tx, sessionId)
@@ -40,12 +112,34 @@ func NewPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, width, height, x, y in
 		return PaneEntry{}, err
 	}
 
-	window, err := GetWindow(tx, sessionId, windowId)
+	if err := checkNotTerminated(session); err != nil {
+		return PaneEntry{}, err
+	}
+
+	window, err := GetWindow(tx, session.ID, windowId)
 	if err != nil {
 		return PaneEntry{}, err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(paneBucketName)
+	if cwd == "" {
+		cwd = window.Cwd
+	}
+	if cwd == "" {
+		cwd = session.Cwd
+	}
+
+	if MaxPanesPerWindow > 0 {
+		count, err := CountPanes(tx, session.ID, window.ID)
+		if err != nil {
+			return PaneEntry{}, err
+		}
+
+		if count >= MaxPanesPerWindow {
+			return PaneEntry{}, ErrPaneLimitReached
+		}
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
 	if err != nil {
 		return PaneEntry{}, err
 	}
@@ -55,20 +149,31 @@ func NewPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, width, height, x, y in
 		return PaneEntry{}, err
 	}
 
-	pane := PaneEntry{
-		ID:         uuid.New(),
-		SsessionID: session.ID,
-		WindowID:   window.ID,
-		Width:      width,
-		Height:     height,
-		X:          x,
-		Y:          y,
-		Cwd:        cwd,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+	if windowBucket.Get([]byte(id.String())) != nil {
+		return PaneEntry{}, ErrPaneAlreadyExists
+	}
+
+	order, err := nextPaneOrder(windowBucket)
+	if err != nil {
+		return PaneEntry{}, err
 	}
 
-	bytes, err := json.Marshal(pane)
+	pane := PaneEntry{
+		SchemaVersion: CurrentSchemaVersion,
+		ID:            id,
+		SsessionID:    session.ID,
+		WindowID:      window.ID,
+		Width:         width,
+		Height:        height,
+		X:             x,
+		Y:             y,
+		Cwd:           cwd,
+		Order:         order,
+		CreatedAt:     now(),
+		UpdatedAt:     now(),
+	}
+
+	bytes, err := marshalEntry(tx, PaneKind, pane)
 	if err != nil {
 		return PaneEntry{}, err
 	}
@@ -78,9 +183,58 @@ func NewPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, width, height, x, y in
 		return PaneEntry{}, err
 	}
 
+	publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneCreated, Pane: pane})
+
 	return pane, nil
 }
 
+// PaneSpec describes a pane to be created by NewPanes.
+type PaneSpec struct {
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+	X      int32  `json:"x"`
+	Y      int32  `json:"y"`
+	Cwd    string `json:"cwd"`
+}
+
+// NewPanes creates one pane per spec and returns them in the same order as
+// specs. Every spec is validated before the first pane is written.
+func NewPanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID, specs []PaneSpec) ([]PaneEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		if _, err := normalizeCwd(spec.Cwd); err != nil {
+			return nil, err
+		}
+	}
+
+	if MaxPanesPerWindow > 0 {
+		count, err := CountPanes(tx, sessionId, windowId)
+		if err != nil {
+			return nil, err
+		}
+
+		if count+len(specs) > MaxPanesPerWindow {
+			return nil, ErrPaneLimitReached
+		}
+	}
+
+	panes := make([]PaneEntry, 0, len(specs))
+
+	for _, spec := range specs {
+		pane, err := NewPane(tx, sessionId, windowId, spec.Width, spec.Height, spec.X, spec.Y, spec.Cwd)
+		if err != nil {
+			return nil, err
+		}
+
+		panes = append(panes, pane)
+	}
+
+	return panes, nil
+}
+
 func GetPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID) (PaneEntry, error) {
 	if tx == nil {
 		return PaneEntry{}, ErrTxnNotFound
@@ -91,7 +245,7 @@ func GetPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID) (PaneEnt
 		return PaneEntry{}, err
 	}
 
-	bucket := tx.Bucket(paneBucketName)
+	bucket := tx.Bucket(PaneBucket)
 	if bucket == nil {
 		return PaneEntry{}, ErrPaneBucketNotFound
 	}
@@ -108,14 +262,84 @@ func GetPane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID) (PaneEnt
 
 	var pane PaneEntry
 
-	if err := json.Unmarshal(bytes, &pane); err != nil {
+	if err := unmarshalEntry(tx, PaneKind, bytes, &pane); err != nil {
 		return PaneEntry{}, err
 	}
 
 	return pane, nil
 }
 
+// FindPane is GetPane for callers that only know the pane's session. It looks
+// in each of the session's windows in turn, so it costs O(windows).
+func FindPane(tx *bbolt.Tx, sessionId, paneId uuid.UUID) (PaneEntry, error) {
+	if tx == nil {
+		return PaneEntry{}, ErrTxnNotFound
+	}
+
+	windows, err := GetWindows(tx, sessionId)
+	if errors.Is(err, ErrWindowBucketNotFound) || errors.Is(err, ErrWindowSessionBucketNotFound) {
+		return PaneEntry{}, ErrPaneNotFound
+	} else if err != nil {
+		return PaneEntry{}, err
+	}
+
+	bucket := tx.Bucket(PaneBucket)
+	if bucket == nil {
+		return PaneEntry{}, ErrPaneNotFound
+	}
+
+	for _, window := range windows {
+		windowBucket := bucket.Bucket([]byte(window.ID.String()))
+		if windowBucket == nil {
+			continue
+		}
+
+		bytes := windowBucket.Get([]byte(paneId.String()))
+		if bytes == nil {
+			continue
+		}
+
+		var pane PaneEntry
+		if err := unmarshalEntry(tx, PaneKind, bytes, &pane); err != nil {
+			return PaneEntry{}, err
+		}
+
+		return pane, nil
+	}
+
+	return PaneEntry{}, ErrPaneNotFound
+}
+
+// CountPanes returns how many panes a window has.
+func CountPanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (int, error) {
+	if tx == nil {
+		return 0, ErrTxnNotFound
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return 0, err
+	}
+
+	bucket := tx.Bucket(PaneBucket)
+	if bucket == nil {
+		return 0, nil
+	}
+
+	windowBucket := bucket.Bucket([]byte(window.ID.String()))
+	if windowBucket == nil {
+		return 0, nil
+	}
+
+	return countKeys(windowBucket), nil
+}
+
+// GetPanes returns the window's panes, or an empty slice if it has none.
 func GetPanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID) ([]PaneEntry, error) {
+	return getPanes(tx, sessionId, windowId, nil)
+}
+
+func getPanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID, corrupt *[]CorruptRecord) ([]PaneEntry, error) {
 	if tx == nil {
 		return nil, ErrTxnNotFound
 	}
@@ -125,22 +349,28 @@ func GetPanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID) ([]PaneEntry, error)
 		return nil, err
 	}
 
-	bucket := tx.Bucket(paneBucketName)
+	bucket := tx.Bucket(PaneBucket)
 	if bucket == nil {
-		return nil, ErrPaneBucketNotFound
+		return make([]PaneEntry, 0), nil
 	}
 
-	windowBucket := bucket.Bucket([]byte(window.ID.String()))
+	key := []byte(window.ID.String())
+
+	windowBucket := bucket.Bucket(key)
 	if windowBucket == nil {
-		return nil, ErrPaneWindowBucketNotFound
+		// Same as in deletePanes: only a missing bucket means no panes.
+		if bucket.Get(key) != nil {
+			return nil, ErrPaneWindowBucketNotFound
+		}
+		return make([]PaneEntry, 0), nil
 	}
 
 	panes := make([]PaneEntry, 0, windowBucket.Stats().KeyN)
 
 	if err = windowBucket.ForEach(func(k, v []byte) error {
 		var pane PaneEntry
-		if err = json.Unmarshal(v, &pane); err != nil {
-			return err
+		if err := unmarshalEntry(windowBucket.Tx(), PaneKind, v, &pane); err != nil {
+			return skipCorrupt(corrupt, k, err)
 		}
 		panes = append(panes, pane)
 		return nil
@@ -151,9 +381,72 @@ func GetPanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID) ([]PaneEntry, error)
 	return panes, nil
 }
 
-func DeletePane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID) error {
+// GetAllPanes returns every pane across all windows of a session.
+func GetAllPanes(tx *bbolt.Tx, sessionId uuid.UUID) ([]PaneEntry, error) {
+	return GetAllPanesCtx(context.Background(), tx, sessionId)
+}
+
+// GetAllPanesCtx is GetAllPanes for long scans: it checks ctx before reading
+// each window and aborts with ctx.Err() once it is done.
+func GetAllPanesCtx(ctx context.Context, tx *bbolt.Tx, sessionId uuid.UUID) ([]PaneEntry, error) {
 	if tx == nil {
-		return ErrTxnNotFound
+		return nil, ErrTxnNotFound
+	}
+
+	windows, err := GetWindows(tx, sessionId)
+	if errors.Is(err, ErrWindowBucketNotFound) || errors.Is(err, ErrWindowSessionBucketNotFound) {
+		return []PaneEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	panes := make([]PaneEntry, 0)
+
+	for _, window := range windows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		windowPanes, err := GetPanes(tx, sessionId, window.ID)
+		if errors.Is(err, ErrPaneBucketNotFound) || errors.Is(err, ErrPaneWindowBucketNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		panes = append(panes, windowPanes...)
+	}
+
+	return panes, nil
+}
+
+// GetPanesByCwd returns the panes of a session whose cwd matches the given
+// directory once both are normalized.
+func GetPanesByCwd(tx *bbolt.Tx, sessionId uuid.UUID, cwd string) ([]PaneEntry, error) {
+	cwd, err := normalizeCwd(cwd)
+	if err != nil {
+		return nil, err
+	}
+
+	panes, err := GetAllPanes(tx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]PaneEntry, 0)
+
+	for _, pane := range panes {
+		if filepath.Clean(pane.Cwd) == cwd || (pane.Cwd == "" && cwd == "") {
+			matches = append(matches, pane)
+		}
+	}
+
+	return matches, nil
+}
+
+func DeletePane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID) error {
+	if err := writableTx(tx); err != nil {
+		return err
 	}
 
 	window, err := GetWindow(tx, sessionId, windowId)
@@ -161,48 +454,127 @@ func DeletePane(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID) error
 		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(paneBucketName)
+	bucket, err := bucketForWrite(tx, PaneBucket, ErrPaneBucketNotFound)
 	if err != nil {
 		return err
 	}
 
-	windowBucket, err := bucket.CreateBucketIfNotExists([]byte(window.ID.String()))
+	windowBucket, err := subBucketForWrite(bucket, []byte(window.ID.String()), ErrPaneWindowBucketNotFound)
 	if err != nil {
 		return err
 	}
 
-	return windowBucket.Delete([]byte(id.String()))
+	if bytes := windowBucket.Get([]byte(id.String())); bytes != nil {
+		var pane PaneEntry
+		if err := unmarshalEntry(tx, PaneKind, bytes, &pane); err != nil {
+			return err
+		}
+
+		publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneDeleted, Pane: pane})
+	}
+
+	if err := windowBucket.Delete([]byte(id.String())); err != nil {
+		return err
+	}
+
+	history := withoutPane(window.FocusHistory, id)
+	if len(history) != len(window.FocusHistory) || window.ZoomedPaneID == id {
+		window.FocusHistory = history
+		if window.ZoomedPaneID == id {
+			window.ZoomedPaneID = uuid.Nil
+		}
+		return putWindow(tx, window)
+	}
+
+	return nil
 }
 
 func DeletePanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID) error {
-	if tx == nil {
-		return ErrTxnNotFound
+	_, err := deletePanes(tx, sessionId, windowId)
+	return err
+}
+
+// deletePanes removes every pane of a window and returns how many there were.
+func deletePanes(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (int, error) {
+	if err := writableTx(tx); err != nil {
+		return 0, err
 	}
 
 	window, err := GetWindow(tx, sessionId, windowId)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(paneBucketName)
+	deleted, err := deletePaneBucket(tx, window)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if err := bucket.DeleteBucket([]byte(window.ID.String())); err != nil {
-		return err
+	if len(window.FocusHistory) > 0 || window.ZoomedPaneID != uuid.Nil {
+		window.FocusHistory, window.ZoomedPaneID = nil, uuid.Nil
+		if err := putWindow(tx, window); err != nil {
+			return 0, err
+		}
 	}
 
-	return nil
+	return deleted, nil
+}
+
+// deletePaneBucket drops the window's pane bucket and returns how many panes
+// it held. Unlike deletePanes it leaves the window entry alone, so cascades
+// that are about to delete the window can call it while iterating over the
+// session's window bucket.
+func deletePaneBucket(tx *bbolt.Tx, window WindowEntry) (int, error) {
+	bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
+	if err != nil {
+		return 0, err
+	}
+
+	key := []byte(window.ID.String())
+
+	windowBucket := bucket.Bucket(key)
+	if windowBucket == nil {
+		// A window without panes has no bucket; anything else stored under
+		// its key means the pane bucket is damaged.
+		if bucket.Get(key) != nil {
+			return 0, ErrPaneWindowBucketNotFound
+		}
+		return 0, nil
+	}
+
+	deleted := 0
+
+	if err := windowBucket.ForEach(func(k, v []byte) error {
+		var pane PaneEntry
+		if err := unmarshalEntry(windowBucket.Tx(), PaneKind, v, &pane); err != nil {
+			return err
+		}
+
+		publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneDeleted, Pane: pane})
+		deleted++
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	if err := bucket.DeleteBucket(key); err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
 }
 
 func UpdatePaneSize(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID, width, height int32) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
 	pane, err := GetPane(tx, sessionId, windowId, id)
 	if err != nil {
 		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(paneBucketName)
+	bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
 	if err != nil {
 		return err
 	}
@@ -212,23 +584,33 @@ func UpdatePaneSize(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID, w
 		return err
 	}
 
-	pane.Width, pane.Height, pane.UpdatedAt = width, height, time.Now()
+	pane.Width, pane.Height, pane.UpdatedAt = width, height, now()
 
-	bytes, err := json.Marshal(pane)
+	bytes, err := marshalEntry(tx, PaneKind, pane)
 	if err != nil {
 		return err
 	}
 
-	return windowBucket.Put([]byte(id.String()), bytes)
+	if err := windowBucket.Put([]byte(id.String()), bytes); err != nil {
+		return err
+	}
+
+	publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneResized, Pane: pane})
+
+	return nil
 }
 
 func UpdatePanePosition(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID, x, y int32) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
 	pane, err := GetPane(tx, sessionId, windowId, id)
 	if err != nil {
 		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(paneBucketName)
+	bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
 	if err != nil {
 		return err
 	}
@@ -238,23 +620,38 @@ func UpdatePanePosition(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUI
 		return err
 	}
 
-	pane.X, pane.Y, pane.UpdatedAt = x, y, time.Now()
+	pane.X, pane.Y, pane.UpdatedAt = x, y, now()
 
-	bytes, err := json.Marshal(pane)
+	bytes, err := marshalEntry(tx, PaneKind, pane)
 	if err != nil {
 		return err
 	}
 
-	return windowBucket.Put([]byte(id.String()), bytes)
+	if err := windowBucket.Put([]byte(id.String()), bytes); err != nil {
+		return err
+	}
+
+	publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneMoved, Pane: pane})
+
+	return nil
 }
 
 func UpdatePaneCwd(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID, cwd string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	cwd, err := normalizeCwd(cwd)
+	if err != nil {
+		return err
+	}
+
 	pane, err := GetPane(tx, sessionId, windowId, id)
 	if err != nil {
 		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(paneBucketName)
+	bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
 	if err != nil {
 		return err
 	}
@@ -264,12 +661,245 @@ func UpdatePaneCwd(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID, cw
 		return err
 	}
 
-	pane.Cwd, pane.UpdatedAt = cwd, time.Now()
+	pane.Cwd, pane.UpdatedAt = cwd, now()
 
-	bytes, err := json.Marshal(pane)
+	bytes, err := marshalEntry(tx, PaneKind, pane)
 	if err != nil {
 		return err
 	}
 
 	return windowBucket.Put([]byte(id.String()), bytes)
 }
+
+// PanePatch lists the pane fields UpdatePane changes. Nil fields are left as
+// they are.
+type PanePatch struct {
+	Width, Height *int32
+	X, Y          *int32
+	Cwd           *string
+	Title         *string
+}
+
+// UpdatePane applies every set field of patch in one write, validating Cwd
+// and Title like UpdatePaneCwd and UpdatePaneTitle. UpdatedAt is bumped once,
+// and PaneResized and PaneMoved are published when the size or position
+// changed. An empty patch writes nothing.
+func UpdatePane(tx *bbolt.Tx, sessionId, windowId, id uuid.UUID, patch PanePatch) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	var cwd, title string
+	var err error
+
+	if patch.Cwd != nil {
+		if cwd, err = normalizeCwd(*patch.Cwd); err != nil {
+			return err
+		}
+	}
+	if patch.Title != nil {
+		if title, err = sanitizeText(*patch.Title, MaxTitleLength); err != nil {
+			return err
+		}
+	}
+
+	pane, err := GetPane(tx, sessionId, windowId, id)
+	if err != nil {
+		return err
+	}
+
+	if patch == (PanePatch{}) {
+		return nil
+	}
+
+	widthChanged := setIfChanged(&pane.Width, patch.Width)
+	heightChanged := setIfChanged(&pane.Height, patch.Height)
+	xChanged := setIfChanged(&pane.X, patch.X)
+	yChanged := setIfChanged(&pane.Y, patch.Y)
+
+	if patch.Cwd != nil {
+		pane.Cwd = cwd
+	}
+	if patch.Title != nil {
+		pane.Title = title
+	}
+
+	pane.UpdatedAt = now()
+
+	if err := putPane(tx, pane); err != nil {
+		return err
+	}
+
+	if widthChanged || heightChanged {
+		publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneResized, Pane: pane})
+	}
+	if xChanged || yChanged {
+		publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneMoved, Pane: pane})
+	}
+
+	return nil
+}
+
+// setIfChanged stores *value in field when value is set and reports whether
+// that changed field.
+func setIfChanged(field *int32, value *int32) bool {
+	if value == nil || *field == *value {
+		return false
+	}
+	*field = *value
+	return true
+}
+
+func putPane(tx *bbolt.Tx, pane PaneEntry) error {
+	bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
+	if err != nil {
+		return err
+	}
+
+	windowBucket, err := bucket.CreateBucketIfNotExists([]byte(pane.WindowID.String()))
+	if err != nil {
+		return err
+	}
+
+	bytes, err := marshalEntry(tx, PaneKind, pane)
+	if err != nil {
+		return err
+	}
+
+	return windowBucket.Put([]byte(pane.ID.String()), bytes)
+}
+
+// ApplyPaneLayout sets the geometry of several panes of a window at once. All
+// pane IDs are resolved before anything is written, so an unknown ID leaves
+// the window untouched. As with UpdatePane, PaneResized and PaneMoved are only
+// published for panes whose size or position changed.
+func ApplyPaneLayout(tx *bbolt.Tx, sessionId, windowId uuid.UUID, layout []PaneGeometry) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	type change struct {
+		pane           PaneEntry
+		resized, moved bool
+	}
+
+	changes := make([]change, 0, len(layout))
+
+	for _, geometry := range layout {
+		pane, err := GetPane(tx, sessionId, windowId, geometry.ID)
+		if err != nil {
+			return err
+		}
+
+		widthChanged := setIfChanged(&pane.Width, &geometry.Width)
+		heightChanged := setIfChanged(&pane.Height, &geometry.Height)
+		xChanged := setIfChanged(&pane.X, &geometry.X)
+		yChanged := setIfChanged(&pane.Y, &geometry.Y)
+
+		changes = append(changes, change{
+			pane:    pane,
+			resized: widthChanged || heightChanged,
+			moved:   xChanged || yChanged,
+		})
+	}
+
+	updatedAt := now()
+
+	for _, c := range changes {
+		// Like UpdatePane, a pane whose geometry is unchanged is not written.
+		if !c.resized && !c.moved {
+			continue
+		}
+
+		c.pane.UpdatedAt = updatedAt
+
+		if err := putPane(tx, c.pane); err != nil {
+			return err
+		}
+
+		if c.resized {
+			publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneResized, Pane: c.pane})
+		}
+		if c.moved {
+			publishOnCommit(tx, PaneEvents, PaneEvent{Type: PaneMoved, Pane: c.pane})
+		}
+	}
+
+	return nil
+}
+
+// nextPaneOrder returns one past the highest Order in the window bucket, or 0
+// if it is empty.
+func nextPaneOrder(windowBucket *bbolt.Bucket) (int, error) {
+	next := 0
+
+	if err := windowBucket.ForEach(func(k, v []byte) error {
+		var pane PaneEntry
+		if err := unmarshalEntry(windowBucket.Tx(), PaneKind, v, &pane); err != nil {
+			return err
+		}
+		next = max(next, pane.Order+1)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return next, nil
+}
+
+// GetPanesByOrder is GetPanes sorted by Order, i.e. the order panes are
+// cycled through.
+func GetPanesByOrder(tx *bbolt.Tx, sessionId, windowId uuid.UUID) ([]PaneEntry, error) {
+	panes, err := GetPanes(tx, sessionId, windowId)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(panes, func(i, j int) bool {
+		return panes[i].Order < panes[j].Order
+	})
+
+	return panes, nil
+}
+
+// MovePaneOrder moves a pane to newOrder within its window and renumbers the
+// window's panes 0..n-1. An order past either end is clamped.
+func MovePaneOrder(tx *bbolt.Tx, sessionId, windowId, paneId uuid.UUID, newOrder int) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	pane, err := GetPane(tx, sessionId, windowId, paneId)
+	if err != nil {
+		return err
+	}
+
+	panes, err := GetPanesByOrder(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	ordered := make([]PaneEntry, 0, len(panes))
+	for _, p := range panes {
+		if p.ID != pane.ID {
+			ordered = append(ordered, p)
+		}
+	}
+
+	newOrder = max(0, min(newOrder, len(ordered)))
+	ordered = slices.Insert(ordered, newOrder, pane)
+
+	for i, p := range ordered {
+		if p.Order == i {
+			continue
+		}
+
+		p.Order, p.UpdatedAt = i, now()
+
+		if err := putPane(tx, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,730 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func seedWindow(t *testing.T, db *bbolt.DB) (uuid.UUID, uuid.UUID) {
+	t.Helper()
+
+	var sessionID, windowID uuid.UUID
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "pane-session")
+		if err != nil {
+			return err
+		}
+		sessionID = session.ID
+
+		window, err := NewWindow(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		windowID = window.ID
+
+		return nil
+	})
+
+	return sessionID, windowID
+}
+
+func TestPaneCwdNormalization(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp//work/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Cwd != "/tmp/work" {
+			t.Fatalf("expected cleaned cwd, got %q", pane.Cwd)
+		}
+
+		if err := UpdatePaneCwd(tx, sessionID, windowID, pane.ID, "/home/../srv/"); err != nil {
+			t.Fatal(err)
+		}
+		pane, err = GetPane(tx, sessionID, windowID, pane.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Cwd != "/srv" {
+			t.Fatalf("expected cleaned cwd, got %q", pane.Cwd)
+		}
+
+		return nil
+	})
+}
+
+func TestPaneRelativeCwd(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "work/src"); !errors.Is(err, ErrRelativeCwd) {
+			t.Fatalf("expected ErrRelativeCwd, got %v", err)
+		}
+
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdatePaneCwd(tx, sessionID, windowID, pane.ID, "./src"); !errors.Is(err, ErrRelativeCwd) {
+			t.Fatalf("expected ErrRelativeCwd, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestPaneEmptyCwd(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Cwd != "" {
+			t.Fatalf("expected empty cwd, got %q", pane.Cwd)
+		}
+
+		return nil
+	})
+}
+
+func TestGetPanesByCwd(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		other, err := NewWindow(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, spec := range []struct {
+			windowID uuid.UUID
+			cwd      string
+		}{
+			{windowID, "/srv/app"},
+			{windowID, "/tmp"},
+			{other.ID, "/srv/app/"},
+			{other.ID, "/home"},
+		} {
+			if _, err := NewPane(tx, sessionID, spec.windowID, 80, 24, 0, 0, spec.cwd); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		panes, err := GetPanesByCwd(tx, sessionID, "/srv/./app/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(panes) != 2 {
+			t.Fatalf("expected 2 panes in /srv/app, got %d", len(panes))
+		}
+		for _, pane := range panes {
+			if pane.Cwd != "/srv/app" {
+				t.Fatalf("unexpected pane cwd %q", pane.Cwd)
+			}
+		}
+
+		panes, err = GetPanesByCwd(tx, sessionID, "/var")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(panes) != 0 {
+			t.Fatalf("expected no panes in /var, got %d", len(panes))
+		}
+
+		return nil
+	})
+}
+
+func TestApplyPaneLayout(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	var paneIDs []uuid.UUID
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for range 4 {
+			pane, err := NewPane(tx, sessionID, windowID, 160, 48, 0, 0, "/tmp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			paneIDs = append(paneIDs, pane.ID)
+		}
+
+		return nil
+	})
+
+	grid := []PaneGeometry{
+		{ID: paneIDs[0], X: 0, Y: 0, Width: 80, Height: 24},
+		{ID: paneIDs[1], X: 80, Y: 0, Width: 80, Height: 24},
+		{ID: paneIDs[2], X: 0, Y: 24, Width: 80, Height: 24},
+		{ID: paneIDs[3], X: 80, Y: 24, Width: 80, Height: 24},
+	}
+
+	// An unknown pane must abort the whole layout before any write. The panes
+	// are read back in the same tx, before rollback could hide a partial write.
+	err := db.Update(func(tx *bbolt.Tx) error {
+		err := ApplyPaneLayout(tx, sessionID, windowID, append(grid[:2:2], PaneGeometry{ID: uuid.New()}))
+
+		for _, id := range paneIDs {
+			pane, getErr := GetPane(tx, sessionID, windowID, id)
+			if getErr != nil {
+				t.Fatal(getErr)
+			}
+			if pane.Width != 160 || pane.Height != 48 {
+				t.Fatalf("expected failed layout to leave pane %s untouched", id)
+			}
+		}
+
+		return err
+	})
+	if !errors.Is(err, ErrPaneNotFound) {
+		t.Fatalf("expected ErrPaneNotFound, got %v", err)
+	}
+
+	// A partial layout only moves the panes it names.
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		return ApplyPaneLayout(tx, sessionID, windowID, grid[:2])
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for i, id := range paneIDs {
+			pane, err := GetPane(tx, sessionID, windowID, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := PaneGeometry{ID: id, Width: 160, Height: 48}
+			if i < 2 {
+				want = grid[i]
+			}
+			if pane.X != want.X || pane.Y != want.Y || pane.Width != want.Width || pane.Height != want.Height {
+				t.Fatalf("pane %s has %dx%d at %d,%d, want %+v", id, pane.Width, pane.Height, pane.X, pane.Y, want)
+			}
+		}
+
+		return ApplyPaneLayout(tx, sessionID, windowID, grid)
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for _, geometry := range grid {
+			pane, err := GetPane(tx, sessionID, windowID, geometry.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pane.X != geometry.X || pane.Y != geometry.Y || pane.Width != geometry.Width || pane.Height != geometry.Height {
+				t.Fatalf("pane %s not updated to %+v", pane.ID, geometry)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestApplyPaneLayoutPublishesChanges(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	var paneIDs []uuid.UUID
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for range 3 {
+			pane, err := NewPane(tx, sessionID, windowID, 160, 48, 0, 0, "/tmp")
+			if err != nil {
+				return err
+			}
+			paneIDs = append(paneIDs, pane.ID)
+		}
+		return nil
+	})
+
+	events, cancel := PaneEvents.Subscribe(8)
+	defer cancel()
+
+	// The first pane is resized, the second only moved and the third keeps
+	// its geometry.
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		return ApplyPaneLayout(tx, sessionID, windowID, []PaneGeometry{
+			{ID: paneIDs[0], Width: 80, Height: 48},
+			{ID: paneIDs[1], X: 80, Width: 160, Height: 48},
+			{ID: paneIDs[2], Width: 160, Height: 48},
+		})
+	})
+
+	type published struct {
+		kind PaneEventType
+		id   uuid.UUID
+	}
+
+	var got []published
+	for done := false; !done; {
+		select {
+		case event := <-events:
+			got = append(got, published{event.Type, event.Pane.ID})
+		case <-time.After(100 * time.Millisecond):
+			done = true
+		}
+	}
+
+	want := []published{{PaneResized, paneIDs[0]}, {PaneMoved, paneIDs[1]}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+}
+
+func TestMaxPanesPerWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	MaxPanesPerWindow = 2
+	t.Cleanup(func() { MaxPanesPerWindow = 0 })
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for range 2 {
+			if _, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, ""); !errors.Is(err, ErrPaneLimitReached) {
+			t.Fatalf("expected ErrPaneLimitReached, got %v", err)
+		}
+
+		count, err := CountPanes(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Fatalf("expected 2 panes, got %d", count)
+		}
+
+		return nil
+	})
+}
+
+func TestNewPanes(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	specs := []PaneSpec{
+		{Width: 40, Height: 24, X: 0, Y: 0, Cwd: "/a"},
+		{Width: 40, Height: 24, X: 40, Y: 0, Cwd: "/b"},
+		{Width: 40, Height: 24, X: 80, Y: 0, Cwd: "/c"},
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		invalid := append(specs[:2:2], PaneSpec{Cwd: "relative"})
+		if _, err := NewPanes(tx, sessionID, windowID, invalid); !errors.Is(err, ErrRelativeCwd) {
+			t.Fatalf("expected ErrRelativeCwd, got %v", err)
+		}
+
+		count, err := CountPanes(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Fatalf("expected invalid batch to write nothing, got %d panes", count)
+		}
+
+		panes, err := NewPanes(tx, sessionID, windowID, specs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(panes) != len(specs) {
+			t.Fatalf("expected %d panes, got %d", len(specs), len(panes))
+		}
+		for i, pane := range panes {
+			if pane.Cwd != specs[i].Cwd || pane.X != specs[i].X {
+				t.Fatalf("pane %d out of order: %+v", i, pane)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestPaneUpdatersRequireWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bogus := uuid.New()
+
+		if err := UpdatePaneSize(tx, sessionID, bogus, pane.ID, 1, 1); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("UpdatePaneSize: expected ErrWindowNotFound, got %v", err)
+		}
+		if err := UpdatePanePosition(tx, sessionID, bogus, pane.ID, 1, 1); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("UpdatePanePosition: expected ErrWindowNotFound, got %v", err)
+		}
+		if err := UpdatePaneCwd(tx, sessionID, bogus, pane.ID, "/home"); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("UpdatePaneCwd: expected ErrWindowNotFound, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestPaneUpdatersRequireSession(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		window, err := GetWindow(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := DeleteSession(tx, sessionID); err != nil {
+			t.Fatal(err)
+		}
+
+		// Leave the window and pane behind as orphans of the deleted session.
+		if err := putWindow(tx, window); err != nil {
+			t.Fatal(err)
+		}
+		if err := putPane(tx, pane); err != nil {
+			t.Fatal(err)
+		}
+
+		updaters := map[string]func() error{
+			"UpdatePaneSize":     func() error { return UpdatePaneSize(tx, sessionID, windowID, pane.ID, 1, 1) },
+			"UpdatePanePosition": func() error { return UpdatePanePosition(tx, sessionID, windowID, pane.ID, 1, 1) },
+			"UpdatePaneCwd":      func() error { return UpdatePaneCwd(tx, sessionID, windowID, pane.ID, "/home") },
+			"UpdatePaneTitle":    func() error { return UpdatePaneTitle(tx, sessionID, windowID, pane.ID, "orphan") },
+			"DeletePane":         func() error { return DeletePane(tx, sessionID, windowID, pane.ID) },
+		}
+		for name, update := range updaters {
+			if err := update(); !errors.Is(err, ErrSessionNotFound) {
+				t.Fatalf("%s: expected ErrSessionNotFound, got %v", name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestPaneUpdatersDecodeWindowOnce(t *testing.T) {
+	db := openTestDB(t)
+	store := NewStore(db)
+	sessionID, windowID := seedWindow(t, db)
+
+	var paneID uuid.UUID
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp")
+		paneID = pane.ID
+		return err
+	})
+
+	codec := &countingCodec{}
+	store.SetCodec(codec)
+	t.Cleanup(func() { store.SetCodec(nil) })
+
+	decodes := func(fn func(tx *bbolt.Tx) error) int64 {
+		t.Helper()
+		codec.unmarshals.Store(0)
+		withTx(t, db, fn)
+		return codec.unmarshals.Load()
+	}
+
+	// An updater validates the window through GetPane alone, so it decodes
+	// exactly what GetPane does.
+	want := decodes(func(tx *bbolt.Tx) error {
+		_, err := GetPane(tx, sessionID, windowID, paneID)
+		return err
+	})
+
+	width := int32(100)
+	updaters := map[string]func(tx *bbolt.Tx) error{
+		"UpdatePaneSize": func(tx *bbolt.Tx) error {
+			return UpdatePaneSize(tx, sessionID, windowID, paneID, 90, 30)
+		},
+		"UpdatePanePosition": func(tx *bbolt.Tx) error {
+			return UpdatePanePosition(tx, sessionID, windowID, paneID, 1, 1)
+		},
+		"UpdatePaneCwd": func(tx *bbolt.Tx) error {
+			return UpdatePaneCwd(tx, sessionID, windowID, paneID, "/home")
+		},
+		"UpdatePane": func(tx *bbolt.Tx) error {
+			return UpdatePane(tx, sessionID, windowID, paneID, PanePatch{Width: &width})
+		},
+	}
+
+	for name, fn := range updaters {
+		if got := decodes(fn); got != want {
+			t.Errorf("%s: expected %d decodes, got %d", name, want, got)
+		}
+	}
+}
+
+func TestNewPaneWithID(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		id := uuid.MustParse("6f1c2b8e-3d4a-4f5b-9c6d-7e8f9a0b1c2d")
+
+		pane, err := NewPaneWithID(tx, id, sessionID, windowID, 80, 24, 0, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.ID != id {
+			t.Fatalf("expected pane id %s, got %s", id, pane.ID)
+		}
+		if _, err := GetPane(tx, sessionID, windowID, id); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewPaneWithID(tx, id, sessionID, windowID, 40, 12, 0, 0, ""); !errors.Is(err, ErrPaneAlreadyExists) {
+			t.Fatalf("expected ErrPaneAlreadyExists, got %v", err)
+		}
+		if _, err := NewPaneWithID(tx, uuid.Nil, sessionID, windowID, 80, 24, 0, 0, ""); !errors.Is(err, ErrInvalidPaneID) {
+			t.Fatalf("expected ErrInvalidPaneID, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestPaneCwdInheritance(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := UpdateSessionCwd(tx, sessionID, "/srv"); err != nil {
+			t.Fatal(err)
+		}
+
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Cwd != "/srv" {
+			t.Fatalf("expected session cwd, got %q", pane.Cwd)
+		}
+
+		if err := UpdateWindowCwd(tx, sessionID, windowID, "/home/work/"); err != nil {
+			t.Fatal(err)
+		}
+
+		pane, err = NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Cwd != "/home/work" {
+			t.Fatalf("expected window cwd, got %q", pane.Cwd)
+		}
+
+		pane, err = NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Cwd != "/tmp" {
+			t.Fatalf("expected explicit cwd, got %q", pane.Cwd)
+		}
+
+		return nil
+	})
+}
+
+func TestFindPane(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "find")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		panes, err := GetAllPanes(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := panes[len(panes)-1]
+
+		found, err := FindPane(tx, sessionID, want.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found.ID != want.ID || found.WindowID != want.WindowID {
+			t.Fatalf("expected pane %s in window %s, got %s in %s", want.ID, want.WindowID, found.ID, found.WindowID)
+		}
+
+		if _, err := FindPane(tx, sessionID, uuid.New()); !errors.Is(err, ErrPaneNotFound) {
+			t.Fatalf("expected ErrPaneNotFound, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestMovePaneOrder(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var ids []uuid.UUID
+		for i := range 3 {
+			pane, err := NewPane(tx, sessionID, windowID, 10, 10, int32(i)*10, 0, "/tmp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pane.Order != i {
+				t.Fatalf("expected pane %d to get order %d, got %d", i, i, pane.Order)
+			}
+			ids = append(ids, pane.ID)
+		}
+
+		expectOrder := func(want ...uuid.UUID) {
+			t.Helper()
+			panes, err := GetPanesByOrder(tx, sessionID, windowID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, pane := range panes {
+				if pane.ID != want[i] || pane.Order != i {
+					t.Fatalf("position %d: expected %s with order %d, got %s with order %d", i, want[i], i, pane.ID, pane.Order)
+				}
+			}
+		}
+
+		// Cycling the first pane to the back rotates the order.
+		if err := MovePaneOrder(tx, sessionID, windowID, ids[0], 2); err != nil {
+			t.Fatal(err)
+		}
+		expectOrder(ids[1], ids[2], ids[0])
+
+		if err := MovePaneOrder(tx, sessionID, windowID, ids[0], -5); err != nil {
+			t.Fatal(err)
+		}
+		expectOrder(ids[0], ids[1], ids[2])
+
+		// A gap left by a delete is closed by the next move.
+		if err := DeletePane(tx, sessionID, windowID, ids[1]); err != nil {
+			t.Fatal(err)
+		}
+		if err := MovePaneOrder(tx, sessionID, windowID, ids[2], 0); err != nil {
+			t.Fatal(err)
+		}
+		expectOrder(ids[2], ids[0])
+
+		return nil
+	})
+}
+
+func TestUpdatePanePatch(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+	cwd := t.TempDir()
+
+	var pane PaneEntry
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		pane, err = NewPane(tx, sessionID, windowID, 80, 24, 5, 3, "")
+		return err
+	})
+
+	width, height := int32(120), int32(40)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		return UpdatePane(tx, sessionID, windowID, pane.ID, PanePatch{
+			Width:  &width,
+			Height: &height,
+			Cwd:    &cwd,
+		})
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		updated, err := GetPane(tx, sessionID, windowID, pane.ID)
+		if err != nil {
+			return err
+		}
+		if updated.Width != 120 || updated.Height != 40 || updated.Cwd != cwd {
+			t.Fatalf("expected a 120x40 pane in %s, got %dx%d in %q", cwd, updated.Width, updated.Height, updated.Cwd)
+		}
+		if updated.X != 5 || updated.Y != 3 {
+			t.Fatalf("expected the position to stay at 5,3, got %d,%d", updated.X, updated.Y)
+		}
+
+		relative := "not/absolute"
+		if err := UpdatePane(tx, sessionID, windowID, pane.ID, PanePatch{Width: &width, Cwd: &relative}); !errors.Is(err, ErrRelativeCwd) {
+			t.Fatalf("expected ErrRelativeCwd, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestGetPanesWithoutPanes(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		panes, err := GetPanes(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatalf("expected no error for a window without panes, got %v", err)
+		}
+		if len(panes) != 0 {
+			t.Fatalf("expected no panes, got %d", len(panes))
+		}
+
+		if _, err := GetPanes(tx, sessionID, uuid.New()); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("expected ErrWindowNotFound for an unknown window, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestNewPaneInDeletedWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := DeleteWindow(tx, sessionID, windowID); err != nil {
+			return err
+		}
+
+		// Leave a pane bucket behind for the deleted window, as a crashed
+		// or older build might have.
+		bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
+		if err != nil {
+			return err
+		}
+		_, err = bucket.CreateBucketIfNotExists([]byte(windowID.String()))
+		return err
+	})
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "")
+		return err
+	})
+	if !errors.Is(err, ErrWindowNotFound) {
+		t.Fatalf("expected ErrWindowNotFound, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		ghost := tx.Bucket(PaneBucket).Bucket([]byte(windowID.String()))
+		if ghost == nil {
+			t.Fatal("expected the leftover pane bucket to still exist")
+		}
+		if n := countKeys(ghost); n != 0 {
+			t.Fatalf("expected no pane in the deleted window's bucket, got %d", n)
+		}
+		return nil
+	})
+}
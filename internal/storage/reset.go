@@ -0,0 +1,26 @@
+package storage
+
+import "go.etcd.io/bbolt"
+
+// ResetDatabase deletes every session, window and pane by dropping their
+// top-level buckets. Saved layouts and the schema version are kept. It is
+// meant for tests and development.
+func ResetDatabase(tx *bbolt.Tx) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	for _, name := range [][]byte{SessionBucket, WindowBucket, PaneBucket} {
+		if tx.Bucket(name) == nil {
+			continue
+		}
+
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+
+	invalidateNamesOnCommit(tx)
+
+	return nil
+}
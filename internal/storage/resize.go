@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+var ErrWindowTooSmall = errors.New("window too small to fit its panes at the minimum pane size")
+
+// MinPaneWidth and MinPaneHeight are the smallest size ResizeWindow will give
+// a pane.
+var (
+	MinPaneWidth  int32 = 1
+	MinPaneHeight int32 = 1
+)
+
+// ResizeWindow scales the panes of a window from their current extent to
+// width x height. Pane edges move proportionally; edges that would leave a
+// pane below MinPaneWidth or MinPaneHeight are pushed apart. If the panes
+// cannot all fit at the minimum size, ErrWindowTooSmall is returned and
+// nothing is written.
+func ResizeWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID, width, height int32) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	panes, err := GetPanes(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	if len(panes) == 0 {
+		return nil
+	}
+
+	fromWidth, fromHeight := paneExtent(panes)
+	columns := make([][2]int32, 0, len(panes))
+	rows := make([][2]int32, 0, len(panes))
+
+	for _, pane := range panes {
+		columns = append(columns, [2]int32{pane.X, pane.X + pane.Width})
+		rows = append(rows, [2]int32{pane.Y, pane.Y + pane.Height})
+	}
+
+	xs, err := fitAxis(columns, fromWidth, width, MinPaneWidth)
+	if err != nil {
+		return err
+	}
+
+	ys, err := fitAxis(rows, fromHeight, height, MinPaneHeight)
+	if err != nil {
+		return err
+	}
+
+	layout := make([]PaneGeometry, 0, len(panes))
+	for _, pane := range panes {
+		x, y := xs[pane.X], ys[pane.Y]
+
+		layout = append(layout, PaneGeometry{
+			ID:     pane.ID,
+			X:      x,
+			Y:      y,
+			Width:  xs[pane.X+pane.Width] - x,
+			Height: ys[pane.Y+pane.Height] - y,
+		})
+	}
+
+	return ApplyPaneLayout(tx, sessionId, windowId, layout)
+}
+
+// WindowCoverage returns the summed area of a window's panes and the area of
+// the window. Windows do not store a size, so like ResizeWindow it takes the
+// window to span from the origin to the panes' furthest edges. Overlapping
+// panes are counted twice, so covered == windowArea means the window is tiled
+// without gaps only if no panes overlap. A window without panes covers 0 of 0.
+func WindowCoverage(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (covered int64, windowArea int64, err error) {
+	if tx == nil {
+		return 0, 0, ErrTxnNotFound
+	}
+
+	panes, err := GetPanes(tx, sessionId, windowId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, pane := range panes {
+		covered += int64(pane.Width) * int64(pane.Height)
+	}
+
+	width, height := paneExtent(panes)
+
+	return covered, int64(width) * int64(height), nil
+}
+
+// paneExtent returns how far the panes reach from the window's origin.
+func paneExtent(panes []PaneEntry) (width, height int32) {
+	for _, pane := range panes {
+		width = max(width, pane.X+pane.Width)
+		height = max(height, pane.Y+pane.Height)
+	}
+	return width, height
+}
+
+// fitAxis maps the pane edges along one axis from a window of size from to one
+// of size to. Each span is a pane's [start, end) on that axis. Edges keep
+// their order and move proportionally, but are pushed apart so every span
+// stays at least minSize long.
+func fitAxis(spans [][2]int32, from, to, minSize int32) (map[int32]int32, error) {
+	edges := []int32{0, from}
+	for _, span := range spans {
+		edges = append(edges, span[0], span[1])
+	}
+	slices.Sort(edges)
+	edges = slices.Compact(edges)
+
+	index := make(map[int32]int, len(edges))
+	for i, edge := range edges {
+		index[edge] = i
+	}
+
+	// ends[i] lists the start edges of spans ending at edge i; starts[i] the
+	// end edges of spans starting there.
+	ends := make([][]int, len(edges))
+	starts := make([][]int, len(edges))
+	for _, span := range spans {
+		start, end := index[span[0]], index[span[1]]
+		ends[end] = append(ends[end], start)
+		starts[start] = append(starts[start], end)
+	}
+
+	n := len(edges)
+	gap := int64(minSize)
+
+	// lo[i] is the smallest position of edge i with every span before it at
+	// minimum size; hi[i] the largest with every span after it at minimum.
+	lo := make([]int64, n)
+	for i := 1; i < n; i++ {
+		lo[i] = lo[i-1]
+		for _, start := range ends[i] {
+			lo[i] = max(lo[i], lo[start]+gap)
+		}
+	}
+
+	if lo[n-1] > int64(to) {
+		return nil, ErrWindowTooSmall
+	}
+
+	hi := make([]int64, n)
+	hi[n-1] = int64(to)
+	for i := n - 2; i >= 0; i-- {
+		hi[i] = hi[i+1]
+		for _, end := range starts[i] {
+			hi[i] = min(hi[i], hi[end]-gap)
+		}
+	}
+
+	positions := make(map[int32]int32, n)
+	placed := make([]int64, n)
+
+	for i, edge := range edges {
+		target := int64(math.Round(float64(edge) * float64(to) / float64(from)))
+		target = max(lo[i], min(target, hi[i]))
+
+		if i > 0 {
+			target = max(target, placed[i-1])
+		}
+		for _, start := range ends[i] {
+			target = max(target, placed[start]+gap)
+		}
+
+		placed[i] = target
+		positions[edge] = int32(target)
+	}
+
+	return positions, nil
+}
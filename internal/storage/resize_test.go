@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestResizeWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	var left, right uuid.UUID
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		panes, err := NewPanes(tx, sessionID, windowID, []PaneSpec{
+			{Width: 78, Height: 24, X: 0, Y: 0},
+			{Width: 2, Height: 24, X: 78, Y: 0},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		left, right = panes[0].ID, panes[1].ID
+		return nil
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := ResizeWindow(tx, sessionID, windowID, 20, 12); err != nil {
+			t.Fatal(err)
+		}
+
+		l, err := GetPane(tx, sessionID, windowID, left)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := GetPane(tx, sessionID, windowID, right)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Width < MinPaneWidth {
+			t.Fatalf("expected narrow pane to keep the minimum width, got %d", r.Width)
+		}
+		if l.X+l.Width != r.X || r.X+r.Width != 20 {
+			t.Fatalf("expected panes to tile 20 columns, got %+v and %+v", l, r)
+		}
+		if l.Height != 12 || r.Height != 12 {
+			t.Fatalf("expected heights scaled to 12, got %d and %d", l.Height, r.Height)
+		}
+
+		return nil
+	})
+}
+
+func TestResizeWindowTooSmall(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	MinPaneWidth = 4
+	t.Cleanup(func() { MinPaneWidth = 1 })
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		_, err := NewPanes(tx, sessionID, windowID, []PaneSpec{
+			{Width: 40, Height: 24, X: 0, Y: 0},
+			{Width: 40, Height: 24, X: 40, Y: 0},
+		})
+		return err
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := ResizeWindow(tx, sessionID, windowID, 7, 24); !errors.Is(err, ErrWindowTooSmall) {
+			t.Fatalf("expected ErrWindowTooSmall, got %v", err)
+		}
+
+		panes, err := GetPanes(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pane := range panes {
+			if pane.Width != 40 {
+				t.Fatalf("expected rejected resize to leave panes untouched, got width %d", pane.Width)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestWindowCoverageTiledGrid(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		// A 2x2 grid filling 80x24.
+		_, err := NewPanes(tx, sessionID, windowID, []PaneSpec{
+			{Width: 40, Height: 12, X: 0, Y: 0},
+			{Width: 40, Height: 12, X: 40, Y: 0},
+			{Width: 40, Height: 12, X: 0, Y: 12},
+			{Width: 40, Height: 12, X: 40, Y: 12},
+		})
+		return err
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		covered, area, err := WindowCoverage(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if area != 80*24 || covered != area {
+			t.Fatalf("expected a fully covered 80x24 window, got %d of %d", covered, area)
+		}
+		return nil
+	})
+}
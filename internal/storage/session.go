@@ -9,21 +9,27 @@ package storage
 //
 //   SESSION (bucket)
 //     ├── <session-id-uuid> → JSON(SessionEntry)
-//     └── __session_lookup__ (bucket)
-//           └── <session-name> → <session-id-uuid>
+//     ├── __session_lookup__ (bucket)
+//     │     └── <session-name> → <session-id-uuid>
+//     └── __session_by_created__ (bucket)
+//           └── <created-at>/<session-id-uuid> → <session-id-uuid>
 //
 // Invariants:
 //   - Session UUIDs are the primary keys.
 //   - Session names are unique and resolved via the lookup bucket.
 //   - Renames and deletes update both buckets atomically.
+//   - Creates and deletes update __session_by_created__ in the same transaction.
 //   - All operations must run inside a BoltDB transaction.
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/cchirag/ira/internal/enums"
 	"github.com/google/uuid"
@@ -38,24 +44,80 @@ var (
 	ErrTxnNotFound           = errors.New("db txn not found")
 	ErrSessionBucketNotFound = errors.New("session bucket not found")
 	ErrLookupBucketNotFound  = errors.New("lookup bucket not found")
+	ErrInvalidSessionStatus  = errors.New("invalid session status")
+	ErrSessionTerminated     = errors.New("session is terminated")
+	ErrInvalidDefaultSize    = errors.New("invalid default size: width and height must be positive")
 )
 
+// DefaultPaneWidth and DefaultPaneHeight size new panes in sessions that have
+// no default size of their own.
+const (
+	DefaultPaneWidth  int32 = 80
+	DefaultPaneHeight int32 = 24
+)
+
+// Bucket names are exported so read-only tools can walk the DB; see the
+// layout above. Never modify them.
 var (
-	sessionBucketName = []byte("SESSION")
-	namePattern       = regexp.MustCompile(`^[A-Za-z_-]{1,64}$`)
-	lookupBucketName  = []byte("__session_lookup__")
+	SessionBucket       = []byte("SESSION")
+	SessionLookupBucket = []byte("__session_lookup__")
 )
 
+var namePattern = regexp.MustCompile(`^[A-Za-z_-]{1,64}$`)
+
+// scanCheckInterval is how many entries a context-aware scan reads between
+// checks of its context.
+const scanCheckInterval = 64
+
+// now is the clock used for every stored timestamp. Tests may replace it.
+var now = func() time.Time {
+	return time.Now().UTC()
+}
+
 type SessionEntry struct {
-	ID        uuid.UUID           `json:"id"`
-	Name      string              `json:"name"`
-	Status    enums.SessionStatus `json:"status"`
-	CreatedAt time.Time           `json:"createdAt"`
-	UpdatedAt time.Time           `json:"updatedAt"`
+	SchemaVersion  int                 `json:"schemaVersion"`
+	ID             uuid.UUID           `json:"id"`
+	Name           string              `json:"name"`
+	Description    string              `json:"description,omitempty"`
+	Cwd            string              `json:"cwd,omitempty"`
+	Status         enums.SessionStatus `json:"status"`
+	ActiveWindowID uuid.UUID           `json:"activeWindowId"`
+	Labels         map[string]string   `json:"labels,omitempty"`
+	Options        map[string]string   `json:"options,omitempty"`
+	// DefaultWidth and DefaultHeight size panes created without an explicit
+	// size; see DefaultSize.
+	DefaultWidth  int32     `json:"defaultWidth,omitempty"`
+	DefaultHeight int32     `json:"defaultHeight,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// AllowTerminatedWrites lets NewWindow, NewPane and UpdateSessionName change
+// sessions whose status is Terminated. By default they are rejected with
+// ErrSessionTerminated.
+var AllowTerminatedWrites = false
+
+func checkNotTerminated(session SessionEntry) error {
+	if session.Status == enums.Terminated && !AllowTerminatedWrites {
+		return ErrSessionTerminated
+	}
+
+	return nil
+}
+
+// isBlank reports whether r renders as nothing: any Unicode space, including
+// no-break spaces, or a zero-width character such as U+200B or U+FEFF.
+func isBlank(r rune) bool {
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return true
+	}
+
+	return unicode.IsSpace(r)
 }
 
 func validateName(name string) (string, error) {
-	name = strings.TrimSpace(name)
+	name = strings.TrimFunc(name, isBlank)
 	if name == "" {
 		return "", ErrEmptySessionName
 	}
@@ -68,8 +130,8 @@ func validateName(name string) (string, error) {
 }
 
 func NewSession(tx *bbolt.Tx, name string) (SessionEntry, error) {
-	if tx == nil {
-		return SessionEntry{}, ErrTxnNotFound
+	if err := writableTx(tx); err != nil {
+		return SessionEntry{}, err
 	}
 
 	name, err := validateName(name)
@@ -77,12 +139,12 @@ func NewSession(tx *bbolt.Tx, name string) (SessionEntry, error) {
 		return SessionEntry{}, err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(sessionBucketName)
+	bucket, err := tx.CreateBucketIfNotExists(SessionBucket)
 	if err != nil {
 		return SessionEntry{}, err
 	}
 
-	lookupBucket, err := bucket.CreateBucketIfNotExists(lookupBucketName)
+	lookupBucket, err := bucket.CreateBucketIfNotExists(SessionLookupBucket)
 	if err != nil {
 		return SessionEntry{}, err
 	}
@@ -99,14 +161,15 @@ func NewSession(tx *bbolt.Tx, name string) (SessionEntry, error) {
 	}
 
 	session := SessionEntry{
-		ID:        uid,
-		Name:      name,
-		Status:    enums.Inactive,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		SchemaVersion: CurrentSchemaVersion,
+		ID:            uid,
+		Name:          name,
+		Status:        enums.Inactive,
+		CreatedAt:     now(),
+		UpdatedAt:     now(),
 	}
 
-	bytes, err := json.Marshal(session)
+	bytes, err := marshalEntry(tx, SessionKind, session)
 	if err != nil {
 		return SessionEntry{}, err
 	}
@@ -119,9 +182,52 @@ func NewSession(tx *bbolt.Tx, name string) (SessionEntry, error) {
 		return SessionEntry{}, err
 	}
 
+	if err := indexSessionCreated(bucket, session); err != nil {
+		return SessionEntry{}, err
+	}
+
+	invalidateNamesOnCommit(tx, session.Name)
+	publishOnCommit(tx, SessionEvents, SessionEvent{Type: SessionCreated, Session: session})
+
 	return session, nil
 }
 
+// GetOrCreateSession returns the session with the given name, creating it if
+// it does not exist yet. created reports whether a new session was made.
+func GetOrCreateSession(tx *bbolt.Tx, name string) (SessionEntry, bool, error) {
+	if err := writableTx(tx); err != nil {
+		return SessionEntry{}, false, err
+	}
+
+	name, err := validateName(name)
+	if err != nil {
+		return SessionEntry{}, false, err
+	}
+
+	if tx.Bucket(SessionBucket) != nil {
+		id, exists, err := sessionWithNameExists(tx, name)
+		if err != nil && !errors.Is(err, ErrLookupBucketNotFound) {
+			return SessionEntry{}, false, err
+		}
+
+		if exists {
+			session, err := GetSession(tx, id)
+			if err != nil {
+				return SessionEntry{}, false, err
+			}
+
+			return session, false, nil
+		}
+	}
+
+	session, err := NewSession(tx, name)
+	if err != nil {
+		return SessionEntry{}, false, err
+	}
+
+	return session, true, nil
+}
+
 func sessionWithNameExists(tx *bbolt.Tx, name string) (uuid.UUID, bool, error) {
 	if tx == nil {
 		return uuid.UUID{}, false, ErrTxnNotFound
@@ -132,12 +238,12 @@ func sessionWithNameExists(tx *bbolt.Tx, name string) (uuid.UUID, bool, error) {
 		return uuid.UUID{}, false, err
 	}
 
-	bucket := tx.Bucket(sessionBucketName)
+	bucket := tx.Bucket(SessionBucket)
 	if bucket == nil {
 		return uuid.UUID{}, false, ErrSessionBucketNotFound
 	}
 
-	lookupBucket := bucket.Bucket(lookupBucketName)
+	lookupBucket := bucket.Bucket(SessionLookupBucket)
 	if lookupBucket == nil {
 		return uuid.UUID{}, false, ErrLookupBucketNotFound
 	}
@@ -161,7 +267,7 @@ func GetSession(tx *bbolt.Tx, id uuid.UUID) (SessionEntry, error) {
 		return SessionEntry{}, ErrTxnNotFound
 	}
 
-	bucket := tx.Bucket(sessionBucketName)
+	bucket := tx.Bucket(SessionBucket)
 	if bucket == nil {
 		return SessionEntry{}, ErrSessionBucketNotFound
 	}
@@ -173,36 +279,153 @@ func GetSession(tx *bbolt.Tx, id uuid.UUID) (SessionEntry, error) {
 
 	var session SessionEntry
 
-	if err := json.Unmarshal(entry, &session); err != nil {
+	if err := unmarshalEntry(tx, SessionKind, entry, &session); err != nil {
 		return SessionEntry{}, err
 	}
 
 	return session, nil
 }
 
+// GetSessionsByIDs returns the sessions for ids in the same order, skipping
+// ones that do not exist. The IDs that were not found are returned as missing.
+func GetSessionsByIDs(tx *bbolt.Tx, ids []uuid.UUID) ([]SessionEntry, []uuid.UUID, error) {
+	if tx == nil {
+		return nil, nil, ErrTxnNotFound
+	}
+
+	bucket := tx.Bucket(SessionBucket)
+	if bucket == nil {
+		return nil, ids, nil
+	}
+
+	sessions := make([]SessionEntry, 0, len(ids))
+	var missing []uuid.UUID
+
+	for _, id := range ids {
+		entry := bucket.Get([]byte(id.String()))
+		if entry == nil {
+			missing = append(missing, id)
+			continue
+		}
+
+		var session SessionEntry
+		if err := unmarshalEntry(tx, SessionKind, entry, &session); err != nil {
+			return nil, nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, missing, nil
+}
+
+// IsSessionNameAvailable reports whether NewSession would accept name. It
+// returns the validation error for a malformed name and false for a taken one.
+func IsSessionNameAvailable(tx *bbolt.Tx, name string) (bool, error) {
+	if tx == nil {
+		return false, ErrTxnNotFound
+	}
+
+	_, exists, err := sessionWithNameExists(tx, name)
+	if errors.Is(err, ErrSessionBucketNotFound) || errors.Is(err, ErrLookupBucketNotFound) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return !exists, nil
+}
+
+// GetSessionByName looks a session up by name. The name is trimmed and
+// validated the same way NewSession does before the lookup.
+func GetSessionByName(tx *bbolt.Tx, name string) (SessionEntry, error) {
+	if tx == nil {
+		return SessionEntry{}, ErrTxnNotFound
+	}
+
+	id, exists, err := sessionWithNameExists(tx, name)
+	if err != nil {
+		return SessionEntry{}, err
+	}
+
+	if !exists {
+		return SessionEntry{}, ErrSessionNotFound
+	}
+
+	return GetSession(tx, id)
+}
+
+// GetSessionNames returns the session names starting with prefix in sorted
+// order. It scans only the name lookup, so no session entry is decoded. A
+// fresh DB has no names.
+func GetSessionNames(tx *bbolt.Tx, prefix string) ([]string, error) {
+	if tx == nil {
+		return nil, ErrTxnNotFound
+	}
+
+	names := make([]string, 0)
+
+	bucket := tx.Bucket(SessionBucket)
+	if bucket == nil {
+		return names, nil
+	}
+
+	lookupBucket := bucket.Bucket(SessionLookupBucket)
+	if lookupBucket == nil {
+		return names, nil
+	}
+
+	cursor := lookupBucket.Cursor()
+
+	for k, _ := cursor.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = cursor.Next() {
+		names = append(names, string(k))
+	}
+
+	return names, nil
+}
+
+// GetSessions returns every session. A DB no session has been written to yet
+// has no session bucket and returns an empty slice.
 func GetSessions(tx *bbolt.Tx) ([]SessionEntry, error) {
+	return GetSessionsCtx(context.Background(), tx)
+}
+
+// GetSessionsCtx is GetSessions for long scans: it checks ctx every
+// scanCheckInterval entries and aborts with ctx.Err() once it is done.
+func GetSessionsCtx(ctx context.Context, tx *bbolt.Tx) ([]SessionEntry, error) {
+	return getSessions(ctx, tx, nil)
+}
+
+func getSessions(ctx context.Context, tx *bbolt.Tx, corrupt *[]CorruptRecord) ([]SessionEntry, error) {
 	if tx == nil {
 		return nil, ErrTxnNotFound
 	}
 
-	bucket := tx.Bucket(sessionBucketName)
+	bucket := tx.Bucket(SessionBucket)
 	if bucket == nil {
-		return nil, ErrSessionBucketNotFound
+		return make([]SessionEntry, 0), nil
 	}
 
 	stat := bucket.Stats()
 	sessions := make([]SessionEntry, 0, stat.KeyN)
+	scanned := 0
 
 	if err := bucket.ForEach(func(k, v []byte) error {
+		if scanned++; scanned%scanCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
 		if v == nil {
 			return nil
 		}
 
 		var session SessionEntry
 
-		err := json.Unmarshal(v, &session)
+		err := unmarshalEntry(tx, SessionKind, v, &session)
 		if err != nil {
-			return err
+			return skipCorrupt(corrupt, k, err)
 		}
 
 		sessions = append(sessions, session)
@@ -215,9 +438,23 @@ func GetSessions(tx *bbolt.Tx) ([]SessionEntry, error) {
 	return sessions, nil
 }
 
+func putSession(tx *bbolt.Tx, session SessionEntry) error {
+	bucket, err := tx.CreateBucketIfNotExists(SessionBucket)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := marshalEntry(tx, SessionKind, session)
+	if err != nil {
+		return err
+	}
+
+	return bucket.Put([]byte(session.ID.String()), bytes)
+}
+
 func UpdateSessionName(tx *bbolt.Tx, id uuid.UUID, name string) error {
-	if tx == nil {
-		return ErrTxnNotFound
+	if err := writableTx(tx); err != nil {
+		return err
 	}
 
 	name, err := validateName(name)
@@ -225,12 +462,12 @@ func UpdateSessionName(tx *bbolt.Tx, id uuid.UUID, name string) error {
 		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(sessionBucketName)
+	bucket, err := bucketForWrite(tx, SessionBucket, ErrSessionBucketNotFound)
 	if err != nil {
 		return err
 	}
 
-	lookupBucket, err := bucket.CreateBucketIfNotExists(lookupBucketName)
+	lookupBucket, err := subBucketForWrite(bucket, SessionLookupBucket, ErrLookupBucketNotFound)
 	if err != nil {
 		return err
 	}
@@ -247,14 +484,24 @@ func UpdateSessionName(tx *bbolt.Tx, id uuid.UUID, name string) error {
 	}
 
 	var session SessionEntry
-	if err = json.Unmarshal(old, &session); err != nil {
+	if err = unmarshalEntry(tx, SessionKind, old, &session); err != nil {
+		return err
+	}
+
+	if err := checkNotTerminated(session); err != nil {
 		return err
 	}
 	oldName := session.Name
 
-	session.Name, session.UpdatedAt = name, time.Now()
+	// Renaming to the current name is a no-op; going through the put/delete
+	// below would drop the lookup key that was just written.
+	if oldName == name {
+		return nil
+	}
+
+	session.Name, session.UpdatedAt = name, now()
 
-	bytes, err := json.Marshal(session)
+	bytes, err := marshalEntry(tx, SessionKind, session)
 	if err != nil {
 		return err
 	}
@@ -271,15 +518,75 @@ func UpdateSessionName(tx *bbolt.Tx, id uuid.UUID, name string) error {
 		return err
 	}
 
+	invalidateNamesOnCommit(tx, oldName, session.Name)
+	publishOnCommit(tx, SessionEvents, SessionEvent{Type: SessionRenamed, Session: session})
+
+	return nil
+}
+
+// SwapSessionNames gives a the name of b and b the name of a. Both lookup keys
+// are rewritten in place, so there is no point where one name is held by both
+// sessions or by neither.
+func SwapSessionNames(tx *bbolt.Tx, a, b uuid.UUID) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	first, err := GetSession(tx, a)
+	if err != nil {
+		return err
+	}
+
+	second, err := GetSession(tx, b)
+	if err != nil {
+		return err
+	}
+
+	if first.ID == second.ID {
+		return nil
+	}
+
+	for _, session := range []SessionEntry{first, second} {
+		if err := checkNotTerminated(session); err != nil {
+			return err
+		}
+	}
+
+	bucket, err := bucketForWrite(tx, SessionBucket, ErrSessionBucketNotFound)
+	if err != nil {
+		return err
+	}
+
+	lookupBucket, err := subBucketForWrite(bucket, SessionLookupBucket, ErrLookupBucketNotFound)
+	if err != nil {
+		return err
+	}
+
+	first.Name, second.Name = second.Name, first.Name
+	invalidateNamesOnCommit(tx, first.Name, second.Name)
+	first.UpdatedAt, second.UpdatedAt = now(), now()
+
+	for _, session := range []SessionEntry{first, second} {
+		if err := putSession(tx, session); err != nil {
+			return err
+		}
+
+		if err := lookupBucket.Put([]byte(session.Name), []byte(session.ID.String())); err != nil {
+			return err
+		}
+
+		publishOnCommit(tx, SessionEvents, SessionEvent{Type: SessionRenamed, Session: session})
+	}
+
 	return nil
 }
 
 func UpdateSessionStatus(tx *bbolt.Tx, id uuid.UUID, status enums.SessionStatus) error {
-	if tx == nil {
-		return ErrTxnNotFound
+	if err := writableTx(tx); err != nil {
+		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(sessionBucketName)
+	bucket, err := bucketForWrite(tx, SessionBucket, ErrSessionBucketNotFound)
 	if err != nil {
 		return err
 	}
@@ -290,14 +597,14 @@ func UpdateSessionStatus(tx *bbolt.Tx, id uuid.UUID, status enums.SessionStatus)
 	}
 
 	var session SessionEntry
-	if err = json.Unmarshal(old, &session); err != nil {
+	if err = unmarshalEntry(tx, SessionKind, old, &session); err != nil {
 		return err
 	}
 
 	session.Status = status
-	session.UpdatedAt = time.Now()
+	session.UpdatedAt = now()
 
-	bytes, err := json.Marshal(session)
+	bytes, err := marshalEntry(tx, SessionKind, session)
 	if err != nil {
 		return err
 	}
@@ -309,17 +616,45 @@ func UpdateSessionStatus(tx *bbolt.Tx, id uuid.UUID, status enums.SessionStatus)
 	return nil
 }
 
-func DeleteSession(tx *bbolt.Tx, id uuid.UUID) error {
-	if tx == nil {
-		return ErrTxnNotFound
+// DefaultSize returns the size for panes created in the session without an
+// explicit one, falling back to DefaultPaneWidth x DefaultPaneHeight.
+func (s SessionEntry) DefaultSize() (width, height int32) {
+	width, height = s.DefaultWidth, s.DefaultHeight
+	if width <= 0 || height <= 0 {
+		return DefaultPaneWidth, DefaultPaneHeight
+	}
+	return width, height
+}
+
+// UpdateSessionDefaultSize sets the size NewWindowWithPane gives panes when
+// the caller leaves it unset.
+func UpdateSessionDefaultSize(tx *bbolt.Tx, id uuid.UUID, width, height int32) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	if width <= 0 || height <= 0 {
+		return ErrInvalidDefaultSize
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(sessionBucketName)
+	session, err := GetSession(tx, id)
 	if err != nil {
 		return err
 	}
 
-	lookupBucket, err := bucket.CreateBucketIfNotExists(lookupBucketName)
+	session.DefaultWidth, session.DefaultHeight, session.UpdatedAt = width, height, now()
+
+	return putSession(tx, session)
+}
+
+// UpdateSessionCwd sets the directory new panes in the session start in when
+// neither the pane nor its window specify one.
+func UpdateSessionCwd(tx *bbolt.Tx, id uuid.UUID, cwd string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	cwd, err := normalizeCwd(cwd)
 	if err != nil {
 		return err
 	}
@@ -329,17 +664,112 @@ func DeleteSession(tx *bbolt.Tx, id uuid.UUID) error {
 		return err
 	}
 
-	if err := DeleteWindows(tx, session.ID); err != nil {
-		return err
+	session.Cwd, session.UpdatedAt = cwd, now()
+
+	return putSession(tx, session)
+}
+
+// UpdateSessionsStatus sets the status of every listed session. Sessions that
+// do not exist are skipped; updated is the number of sessions changed. Any
+// other error aborts the whole batch.
+func UpdateSessionsStatus(tx *bbolt.Tx, ids []uuid.UUID, status enums.SessionStatus) (updated int, err error) {
+	if err := writableTx(tx); err != nil {
+		return 0, err
+	}
+
+	if _, ok := enums.SessionStatusName[status]; !ok {
+		return 0, ErrInvalidSessionStatus
+	}
+
+	for _, id := range ids {
+		if err := UpdateSessionStatus(tx, id, status); errors.Is(err, ErrSessionNotFound) {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		updated++
+	}
+
+	return updated, nil
+}
+
+func DeleteSession(tx *bbolt.Tx, id uuid.UUID) error {
+	_, _, err := DeleteSessionCounts(tx, id)
+	return err
+}
+
+// PreviewDeleteSession returns the windows and panes DeleteSession would
+// remove along with the session, without changing anything. It only needs a
+// read transaction.
+func PreviewDeleteSession(tx *bbolt.Tx, id uuid.UUID) (windows []WindowEntry, panes []PaneEntry, err error) {
+	if tx == nil {
+		return nil, nil, ErrTxnNotFound
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	windows, err = GetWindows(tx, session.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, window := range windows {
+		windowPanes, err := GetPanes(tx, session.ID, window.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		panes = append(panes, windowPanes...)
+	}
+
+	return windows, panes, nil
+}
+
+// DeleteSessionCounts deletes a session like DeleteSession and reports how
+// many windows and panes were removed with it.
+func DeleteSessionCounts(tx *bbolt.Tx, id uuid.UUID) (windows, panes int, err error) {
+	if err := writableTx(tx); err != nil {
+		return 0, 0, err
+	}
+
+	bucket, err := bucketForWrite(tx, SessionBucket, ErrSessionBucketNotFound)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	lookupBucket, err := subBucketForWrite(bucket, SessionLookupBucket, ErrLookupBucketNotFound)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	windows, panes, err = deleteWindows(tx, session.ID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("delete windows of session %s: %w", session.ID, err)
 	}
 
 	if err := bucket.Delete([]byte(session.ID.String())); err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	if err := lookupBucket.Delete([]byte(session.Name)); err != nil {
-		return err
+		return 0, 0, err
 	}
 
-	return nil
+	if err := unindexSessionCreated(bucket, session); err != nil {
+		return 0, 0, err
+	}
+
+	invalidateNamesOnCommit(tx, session.Name)
+	publishOnCommit(tx, SessionEvents, SessionEvent{Type: SessionDeleted, Session: session})
+
+	return windows, panes, nil
 }
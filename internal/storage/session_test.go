@@ -0,0 +1,620 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cchirag/ira/internal/enums"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestGetOrCreateSession(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		first, created, err := GetOrCreateSession(tx, "work")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !created {
+			t.Fatal("expected session to be created")
+		}
+
+		second, created, err := GetOrCreateSession(tx, " work ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if created {
+			t.Fatal("expected existing session to be returned")
+		}
+		if second.ID != first.ID {
+			t.Fatalf("expected id %s, got %s", first.ID, second.ID)
+		}
+
+		sessions, err := GetSessions(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 {
+			t.Fatalf("expected 1 session, got %d", len(sessions))
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateSessionNameSameName(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "work")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := UpdateSessionName(tx, session.ID, " work "); err != nil {
+			t.Fatal(err)
+		}
+
+		id, exists, err := sessionWithNameExists(tx, "work")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exists || id != session.ID {
+			t.Fatalf("expected lookup to resolve to %s, got %s (exists=%v)", session.ID, id, exists)
+		}
+
+		return nil
+	})
+}
+
+func TestSwapSessionNames(t *testing.T) {
+	db := openTestDB(t)
+
+	var a, b SessionEntry
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		if a, err = NewSession(tx, "alpha"); err != nil {
+			return err
+		}
+		b, err = NewSession(tx, "beta")
+		return err
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		return SwapSessionNames(tx, a.ID, b.ID)
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for name, want := range map[string]uuid.UUID{"alpha": b.ID, "beta": a.ID} {
+			session, err := GetSessionByName(tx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.ID != want || session.Name != name {
+				t.Fatalf("expected %q to resolve to %s, got %s named %q", name, want, session.ID, session.Name)
+			}
+		}
+
+		names, err := GetSessionNames(tx, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 2 {
+			t.Fatalf("expected 2 lookup keys, got %v", names)
+		}
+		return nil
+	})
+}
+
+func TestDeleteSessionWrapsCascadeErrors(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	// Store a plain value where the window's pane bucket should be.
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(PaneBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(windowID.String()), []byte("garbage"))
+	})
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		return DeleteSession(tx, sessionID)
+	})
+	if !errors.Is(err, ErrPaneWindowBucketNotFound) {
+		t.Fatalf("expected wrapped ErrPaneWindowBucketNotFound, got %v", err)
+	}
+	if !strings.Contains(err.Error(), windowID.String()) {
+		t.Fatalf("expected error to name the window, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := GetSession(tx, sessionID); err != nil {
+			t.Fatalf("expected session to survive the rolled back delete, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestDeleteSessionWithEmptyWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, _ := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := DeleteSession(tx, sessionID); err != nil {
+			t.Fatal(err)
+		}
+		return nil
+	})
+}
+
+func TestSessionTimestampsAreUTC(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "utc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.CreatedAt.Location() != time.UTC {
+			t.Fatalf("expected UTC, got %s", session.CreatedAt.Location())
+		}
+
+		stored, err := GetSession(tx, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.CreatedAt.Location() != time.UTC || stored.UpdatedAt.Location() != time.UTC {
+			t.Fatalf("expected stored timestamps in UTC, got %s", stored.CreatedAt.Location())
+		}
+
+		return nil
+	})
+}
+
+func stubClock(t *testing.T, times ...time.Time) {
+	t.Helper()
+
+	original := now
+	t.Cleanup(func() { now = original })
+
+	now = func() time.Time {
+		current := times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return current
+	}
+}
+
+func TestSessionClockStub(t *testing.T) {
+	db := openTestDB(t)
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	renamed := created.Add(time.Hour)
+	stubClock(t, created, created, renamed)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "clock")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !session.CreatedAt.Equal(created) || !session.UpdatedAt.Equal(created) {
+			t.Fatalf("expected stubbed timestamps, got %s / %s", session.CreatedAt, session.UpdatedAt)
+		}
+
+		if err := UpdateSessionName(tx, session.ID, "clock-renamed"); err != nil {
+			t.Fatal(err)
+		}
+
+		session, err = GetSession(tx, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !session.CreatedAt.Equal(created) {
+			t.Fatalf("expected CreatedAt to stay %s, got %s", created, session.CreatedAt)
+		}
+		if !session.UpdatedAt.Equal(renamed) {
+			t.Fatalf("expected UpdatedAt %s, got %s", renamed, session.UpdatedAt)
+		}
+
+		return nil
+	})
+}
+
+func TestUpdateSessionsStatus(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var ids []uuid.UUID
+		for _, name := range []string{"one", "two", "three"} {
+			session, err := NewSession(tx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, session.ID)
+		}
+
+		if _, err := UpdateSessionsStatus(tx, ids, enums.SessionStatus(42)); !errors.Is(err, ErrInvalidSessionStatus) {
+			t.Fatalf("expected ErrInvalidSessionStatus, got %v", err)
+		}
+
+		updated, err := UpdateSessionsStatus(tx, append(ids, uuid.New()), enums.Terminated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated != 3 {
+			t.Fatalf("expected 3 updated sessions, got %d", updated)
+		}
+
+		for _, id := range ids {
+			session, err := GetSession(tx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if session.Status != enums.Terminated {
+				t.Fatalf("expected %s to be terminated, got %s", session.Name, session.Status)
+			}
+		}
+
+		return nil
+	})
+}
+
+// cancelAfter is a context that reports itself cancelled after its Err method
+// has been called n times, so a scan can be cut off at a known point.
+type cancelAfter struct {
+	context.Context
+	n int
+}
+
+func (c *cancelAfter) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func sessionName(i int) string {
+	name := []byte{}
+	for {
+		name = append(name, byte('a'+i%26))
+		if i /= 26; i == 0 {
+			return string(name)
+		}
+	}
+}
+
+func TestGetSessionsCtxCancel(t *testing.T) {
+	db := openTestDB(t)
+
+	const total = 1000
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for i := range total {
+			if _, err := NewSession(tx, sessionName(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return nil
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		sessions, err := GetSessionsCtx(context.Background(), tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != total {
+			t.Fatalf("expected %d sessions, got %d", total, len(sessions))
+		}
+
+		_, err = GetSessionsCtx(&cancelAfter{Context: context.Background(), n: 3}, tx)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestDeleteSessionCounts(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "counted")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		windows, panes, err := DeleteSessionCounts(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if windows != 2 || panes != 4 {
+			t.Fatalf("expected 2 windows and 4 panes deleted, got %d and %d", windows, panes)
+		}
+		return nil
+	})
+}
+
+func TestSessionNamesAreTrimmed(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		created, err := NewSession(tx, "work")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		session, err := GetSessionByName(tx, "  work  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.ID != created.ID {
+			t.Fatalf("expected session %s, got %s", created.ID, session.ID)
+		}
+
+		if _, err := NewSession(tx, " work\t"); !errors.Is(err, ErrSessionAlreadyExists) {
+			t.Fatalf("expected ErrSessionAlreadyExists, got %v", err)
+		}
+
+		if err := UpdateSessionName(tx, created.ID, "  play "); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := GetSessionByName(tx, "work"); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected old name to be gone, got %v", err)
+		}
+		if session, err = GetSessionByName(tx, "play  "); err != nil || session.Name != "play" {
+			t.Fatalf("expected trimmed rename, got %q, %v", session.Name, err)
+		}
+
+		return nil
+	})
+}
+
+func TestBlankSessionNames(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for _, name := range []string{"\u00a0", "\u00a0\u3000 ", "\u200b", "\ufeff\u2060"} {
+			if _, err := NewSession(tx, name); !errors.Is(err, ErrEmptySessionName) {
+				t.Fatalf("expected ErrEmptySessionName for %q, got %v", name, err)
+			}
+		}
+
+		session, err := NewSession(tx, "\u200bwork\u00a0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.Name != "work" {
+			t.Fatalf("expected blank runes trimmed, got %q", session.Name)
+		}
+
+		return nil
+	})
+}
+
+func TestGetSessionsByIDs(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		a, err := NewSession(tx, "batch-a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewSession(tx, "batch-b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		unknown := uuid.New()
+
+		sessions, missing, err := GetSessionsByIDs(tx, []uuid.UUID{b.ID, unknown, a.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 2 || sessions[0].ID != b.ID || sessions[1].ID != a.ID {
+			t.Fatalf("expected sessions b, a in request order, got %+v", sessions)
+		}
+		if len(missing) != 1 || missing[0] != unknown {
+			t.Fatalf("expected %s to be missing, got %v", unknown, missing)
+		}
+
+		return nil
+	})
+}
+
+func TestPreviewDeleteSession(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "preview")
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		windows, panes, err := PreviewDeleteSession(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		if len(windows) != 2 || len(panes) != 4 {
+			t.Fatalf("expected 2 windows and 4 panes in preview, got %d and %d", len(windows), len(panes))
+		}
+		for _, pane := range panes {
+			if pane.SsessionID != sessionID {
+				t.Fatalf("preview includes pane %s of another session", pane.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := GetSession(tx, sessionID); err != nil {
+			t.Fatalf("expected preview to leave session in place, got %v", err)
+		}
+		if count, err := CountWindows(tx, sessionID); err != nil || count != 2 {
+			t.Fatalf("expected preview to leave 2 windows, got %d (%v)", count, err)
+		}
+		return nil
+	})
+}
+
+func TestTerminatedSessionRejectsWrites(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := UpdateSessionStatus(tx, sessionID, enums.Terminated); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewWindow(tx, sessionID); !errors.Is(err, ErrSessionTerminated) {
+			t.Fatalf("NewWindow: expected ErrSessionTerminated, got %v", err)
+		}
+		if _, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, ""); !errors.Is(err, ErrSessionTerminated) {
+			t.Fatalf("NewPane: expected ErrSessionTerminated, got %v", err)
+		}
+		if err := UpdateSessionName(tx, sessionID, "renamed"); !errors.Is(err, ErrSessionTerminated) {
+			t.Fatalf("UpdateSessionName: expected ErrSessionTerminated, got %v", err)
+		}
+
+		return nil
+	})
+
+	AllowTerminatedWrites = true
+	t.Cleanup(func() { AllowTerminatedWrites = false })
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := NewWindow(tx, sessionID); err != nil {
+			t.Fatalf("expected bypass to allow NewWindow, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestIsSessionNameAvailable(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if ok, err := IsSessionNameAvailable(tx, "fresh"); err != nil || !ok {
+			t.Fatalf("expected fresh to be available on an empty db, got %v, %v", ok, err)
+		}
+
+		if _, err := NewSession(tx, "taken"); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := IsSessionNameAvailable(tx, " taken "); err != nil || ok {
+			t.Fatalf("expected taken to be unavailable, got %v, %v", ok, err)
+		}
+		if ok, err := IsSessionNameAvailable(tx, "fresh"); err != nil || !ok {
+			t.Fatalf("expected fresh to be available, got %v, %v", ok, err)
+		}
+		if _, err := IsSessionNameAvailable(tx, "no spaces"); !errors.Is(err, ErrInvalidSessionName) {
+			t.Fatalf("expected ErrInvalidSessionName, got %v", err)
+		}
+		if _, err := IsSessionNameAvailable(tx, "  "); !errors.Is(err, ErrEmptySessionName) {
+			t.Fatalf("expected ErrEmptySessionName, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestSessionDefaultSize(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "sized")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, pane, err := NewWindowWithPane(tx, session.ID, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Width != DefaultPaneWidth || pane.Height != DefaultPaneHeight {
+			t.Fatalf("expected %dx%d without session defaults, got %dx%d", DefaultPaneWidth, DefaultPaneHeight, pane.Width, pane.Height)
+		}
+
+		if err := UpdateSessionDefaultSize(tx, session.ID, 0, 40); !errors.Is(err, ErrInvalidDefaultSize) {
+			t.Fatalf("expected ErrInvalidDefaultSize, got %v", err)
+		}
+		if err := UpdateSessionDefaultSize(tx, session.ID, 120, 40); err != nil {
+			t.Fatal(err)
+		}
+
+		window, pane, err := NewWindowWithPane(tx, session.ID, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.WindowID != window.ID || pane.Width != 120 || pane.Height != 40 {
+			t.Fatalf("expected a 120x40 pane in window %s, got %+v", window.ID, pane)
+		}
+
+		// An explicit size still wins.
+		if _, pane, err = NewWindowWithPane(tx, session.ID, 100, 0); err != nil {
+			t.Fatal(err)
+		}
+		if pane.Width != 100 || pane.Height != 40 {
+			t.Fatalf("expected 100x40, got %dx%d", pane.Width, pane.Height)
+		}
+		return nil
+	})
+}
+
+func TestGetSessionsOnEmptyDB(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		sessions, err := GetSessions(tx)
+		if err != nil {
+			return err
+		}
+		if len(sessions) != 0 {
+			t.Fatalf("expected no sessions, got %d", len(sessions))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteSessionWithFocusedAndZoomedWindows(t *testing.T) {
+	db := openTestDB(t)
+
+	var sessionID uuid.UUID
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "focused")
+		if err != nil {
+			return err
+		}
+		sessionID = session.ID
+
+		for range 20 {
+			window, pane, err := NewWindowWithPane(tx, session.ID, 0, 0)
+			if err != nil {
+				return err
+			}
+			if err := SetActivePane(tx, session.ID, window.ID, pane.ID); err != nil {
+				return err
+			}
+			if err := SetPaneZoom(tx, session.ID, window.ID, pane.ID, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		windows, panes, err := DeleteSessionCounts(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		if windows != 20 || panes != 20 {
+			t.Fatalf("expected 20 windows and 20 panes deleted, got %d and %d", windows, panes)
+		}
+		return nil
+	})
+}
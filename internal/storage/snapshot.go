@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// SnapshotVersion is the format version written into every Snapshot.
+const SnapshotVersion = 1
+
+var (
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+	ErrInvalidSnapshot            = errors.New("invalid snapshot")
+	ErrInvalidImportMode          = errors.New("invalid import mode")
+)
+
+// Snapshot is a portable copy of every session tree in the db. Unlike a copy
+// of the bbolt file it does not depend on the bbolt version or the machine's
+// architecture. Sessions are ordered by creation time.
+type Snapshot struct {
+	Version  int             `json:"version"`
+	Sessions []SessionExport `json:"sessions"`
+}
+
+// ImportMode says what ImportAll does with the sessions already in the db.
+type ImportMode int
+
+const (
+	// Merge keeps the existing sessions and skips every snapshot session
+	// whose name or ID is already taken.
+	Merge ImportMode = iota
+	// Replace deletes every session, window and pane first, as
+	// ResetDatabase does, then imports the whole snapshot.
+	Replace
+)
+
+// ExportAll returns a Snapshot of every session tree as compact JSON.
+func ExportAll(tx *bbolt.Tx) ([]byte, error) {
+	if tx == nil {
+		return nil, ErrTxnNotFound
+	}
+
+	snapshot := Snapshot{Version: SnapshotVersion, Sessions: make([]SessionExport, 0)}
+
+	sessions, err := GetSessionsSorted(tx, ByCreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		export, err := exportSession(tx, session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("export session %s: %w", session.ID, err)
+		}
+		snapshot.Sessions = append(snapshot.Sessions, export)
+	}
+
+	return json.Marshal(snapshot)
+}
+
+// ImportAll restores a Snapshot written by ExportAll. Sessions, windows and
+// panes keep their IDs. It stops at the first error; run it in its own
+// Update so that a failure rolls back everything it wrote, including a
+// Replace's wipe.
+func ImportAll(tx *bbolt.Tx, data []byte, mode ImportMode) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	if mode != Merge && mode != Replace {
+		return fmt.Errorf("%w: %d", ErrInvalidImportMode, mode)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+
+	if snapshot.Version != SnapshotVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedSnapshotVersion, snapshot.Version)
+	}
+
+	if mode == Replace {
+		if err := ResetDatabase(tx); err != nil {
+			return err
+		}
+	}
+
+	for _, export := range snapshot.Sessions {
+		if mode == Merge {
+			_, exists, err := sessionWithNameExists(tx, export.Session.Name)
+			if err != nil && !errors.Is(err, ErrSessionBucketNotFound) && !errors.Is(err, ErrLookupBucketNotFound) {
+				return err
+			}
+
+			if exists {
+				continue
+			}
+
+			if _, err := GetSession(tx, export.Session.ID); err == nil {
+				continue
+			} else if !errors.Is(err, ErrSessionNotFound) && !errors.Is(err, ErrSessionBucketNotFound) {
+				return err
+			}
+		}
+
+		if _, err := importSession(tx, export, true); err != nil {
+			return fmt.Errorf("import session %q: %w", export.Session.Name, err)
+		}
+	}
+
+	return nil
+}
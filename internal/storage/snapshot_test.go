@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func countTree(t *testing.T, db *bbolt.DB) (sessions, windows, panes int) {
+	t.Helper()
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		all, err := GetSessions(tx)
+		if err != nil {
+			return err
+		}
+		sessions = len(all)
+
+		for _, session := range all {
+			sessionWindows, err := GetWindows(tx, session.ID)
+			if err != nil {
+				return err
+			}
+			windows += len(sessionWindows)
+
+			sessionPanes, err := GetAllPanes(tx, session.ID)
+			if err != nil {
+				return err
+			}
+			panes += len(sessionPanes)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return sessions, windows, panes
+}
+
+func TestExportAllImportAll(t *testing.T) {
+	source := openTestDB(t)
+	buildTree(t, source, "snapshot-a")
+	buildTree(t, source, "snapshot-b")
+	withTx(t, source, func(tx *bbolt.Tx) error {
+		_, err := NewSession(tx, "snapshot-empty")
+		return err
+	})
+
+	var data []byte
+	if err := source.View(func(tx *bbolt.Tx) error {
+		var err error
+		data, err = ExportAll(tx)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	destination, err := bbolt.Open(filepath.Join(t.TempDir(), "restore.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+
+	withTx(t, destination, func(tx *bbolt.Tx) error {
+		return ImportAll(tx, data, Merge)
+	})
+
+	wantSessions, wantWindows, wantPanes := countTree(t, source)
+	sessions, windows, panes := countTree(t, destination)
+	if sessions != wantSessions || windows != wantWindows || panes != wantPanes {
+		t.Fatalf("expected %d sessions, %d windows and %d panes, got %d, %d and %d",
+			wantSessions, wantWindows, wantPanes, sessions, windows, panes)
+	}
+
+	// Merging the same snapshot again skips every session as a collision.
+	withTx(t, destination, func(tx *bbolt.Tx) error {
+		return ImportAll(tx, data, Merge)
+	})
+	if sessions, _, _ := countTree(t, destination); sessions != wantSessions {
+		t.Fatalf("expected a repeated merge to skip all %d sessions, got %d", wantSessions, sessions)
+	}
+
+	// Replace drops what is there before importing.
+	withTx(t, destination, func(tx *bbolt.Tx) error {
+		_, err := NewSession(tx, "local-only")
+		return err
+	})
+	withTx(t, destination, func(tx *bbolt.Tx) error {
+		return ImportAll(tx, data, Replace)
+	})
+	if sessions, _, _ := countTree(t, destination); sessions != wantSessions {
+		t.Fatalf("expected %d sessions after a replace, got %d", wantSessions, sessions)
+	}
+}
+
+func TestImportAllRejectsUnknownVersion(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		return ImportAll(tx, []byte(`{"version":99,"sessions":[]}`), Merge)
+	})
+	if !errors.Is(err, ErrUnsupportedSnapshotVersion) {
+		t.Fatalf("expected ErrUnsupportedSnapshotVersion, got %v", err)
+	}
+}
+
+func TestImportAllInvalidSnapshot(t *testing.T) {
+	db := openTestDB(t)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		return ImportAll(tx, []byte("not json"), Merge)
+	})
+	if !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("expected ErrInvalidSnapshot, got %v", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		return ImportAll(tx, []byte(`{"version":1,"sessions":[]}`), ImportMode(7))
+	})
+	if !errors.Is(err, ErrInvalidImportMode) {
+		t.Fatalf("expected ErrInvalidImportMode, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package storage
+
+import "go.etcd.io/bbolt"
+
+type BucketStats struct {
+	Name      string `json:"name"`
+	KeyCount  int    `json:"keyCount"`
+	Buckets   int    `json:"buckets"`
+	SizeBytes int    `json:"sizeBytes"`
+}
+
+// GetBucketStats reports key counts and in-use sizes of the top-level
+// buckets, including everything nested under them. Missing buckets are
+// reported with zero values. It only reads, so it can run inside a View.
+func GetBucketStats(tx *bbolt.Tx) ([]BucketStats, error) {
+	if tx == nil {
+		return nil, ErrTxnNotFound
+	}
+
+	names := [][]byte{SessionBucket, WindowBucket, PaneBucket}
+	stats := make([]BucketStats, 0, len(names))
+
+	for _, name := range names {
+		entry := BucketStats{Name: string(name)}
+
+		if bucket := tx.Bucket(name); bucket != nil {
+			s := bucket.Stats()
+			entry.KeyCount = s.KeyN
+			entry.Buckets = s.BucketN
+			entry.SizeBytes = s.BranchInuse + s.LeafInuse + s.InlineBucketInuse
+		}
+
+		stats = append(stats, entry)
+	}
+
+	return stats, nil
+}
+
+// countKeys counts the direct keys of a bucket. Unlike Bucket.Stats it sees
+// writes made earlier in the same transaction.
+func countKeys(bucket *bbolt.Bucket) int {
+	count := 0
+
+	cursor := bucket.Cursor()
+	for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+		count++
+	}
+
+	return count
+}
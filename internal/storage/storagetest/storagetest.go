@@ -0,0 +1,76 @@
+// Package storagetest provides helpers for seeding a bbolt db with sessions,
+// windows and panes in tests, both in storage and in the service layer.
+package storagetest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cchirag/ira/internal/storage"
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// Seeded holds the IDs created by SeedSession. PaneIDs[i] are the panes of
+// WindowIDs[i].
+type Seeded struct {
+	SessionID uuid.UUID
+	WindowIDs []uuid.UUID
+	PaneIDs   [][]uuid.UUID
+}
+
+// OpenDB opens a db in a temp dir that is closed when the test ends.
+func OpenDB(t testing.TB) *bbolt.DB {
+	t.Helper()
+
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// SeedSession creates a session with the given name, windows windows and
+// panesPerWindow 80x24 panes in each, all in one transaction. It fails the
+// test on any error.
+func SeedSession(t testing.TB, db *bbolt.DB, name string, windows, panesPerWindow int) Seeded {
+	t.Helper()
+
+	var seeded Seeded
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		session, err := storage.NewSession(tx, name)
+		if err != nil {
+			return err
+		}
+		seeded.SessionID = session.ID
+
+		for range windows {
+			window, err := storage.NewWindow(tx, session.ID)
+			if err != nil {
+				return err
+			}
+
+			paneIDs := make([]uuid.UUID, 0, panesPerWindow)
+			for range panesPerWindow {
+				pane, err := storage.NewPane(tx, session.ID, window.ID, 80, 24, 0, 0, "")
+				if err != nil {
+					return err
+				}
+				paneIDs = append(paneIDs, pane.ID)
+			}
+
+			seeded.WindowIDs = append(seeded.WindowIDs, window.ID)
+			seeded.PaneIDs = append(seeded.PaneIDs, paneIDs)
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return seeded
+}
@@ -0,0 +1,48 @@
+package storagetest
+
+import (
+	"testing"
+
+	"github.com/cchirag/ira/internal/storage"
+	"go.etcd.io/bbolt"
+)
+
+func TestSeedSession(t *testing.T) {
+	db := OpenDB(t)
+	seeded := SeedSession(t, db, "seeded", 3, 2)
+
+	if len(seeded.WindowIDs) != 3 || len(seeded.PaneIDs) != 3 {
+		t.Fatalf("expected 3 windows, got %d windows and %d pane lists", len(seeded.WindowIDs), len(seeded.PaneIDs))
+	}
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		windows, err := storage.GetWindows(tx, seeded.SessionID)
+		if err != nil {
+			return err
+		}
+		if len(windows) != 3 {
+			t.Fatalf("expected 3 stored windows, got %d", len(windows))
+		}
+
+		for i, id := range seeded.WindowIDs {
+			window, err := storage.GetWindow(tx, seeded.SessionID, id)
+			if err != nil {
+				return err
+			}
+			if window.Index != i {
+				t.Fatalf("expected window %s at index %d, got %d", id, i, window.Index)
+			}
+
+			count, err := storage.CountPanes(tx, seeded.SessionID, id)
+			if err != nil {
+				return err
+			}
+			if count != 2 || len(seeded.PaneIDs[i]) != 2 {
+				t.Fatalf("expected 2 panes in window %d, got %d stored and %d seeded", i, count, len(seeded.PaneIDs[i]))
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
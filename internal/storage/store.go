@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+
+	"go.etcd.io/bbolt"
+)
+
+var ErrInvalidWorkspace = errors.New("invalid workspace: must be 1–64 characters: letters, digits, _, - only")
+
+var workspacePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// WorkspacePath returns the db file for a workspace under dir. The default
+// workspace ("") keeps the original "ira" file; any other workspace uses
+// "ira-<workspace>.db".
+func WorkspacePath(dir, workspace string) (string, error) {
+	if workspace == "" {
+		return filepath.Join(dir, "ira"), nil
+	}
+
+	if !workspacePattern.MatchString(workspace) {
+		return "", ErrInvalidWorkspace
+	}
+
+	return filepath.Join(dir, "ira-"+workspace+".db"), nil
+}
+
+// Store owns the bbolt DB behind the storage functions. Whoever opens a Store
+// with OpenStore is responsible for closing it; callers handed the DB through
+// Store.DB must not close it themselves.
+type Store struct {
+	db *bbolt.DB
+}
+
+// OpenStore opens (creating if needed) the bbolt DB at path.
+func OpenStore(path string, opts *bbolt.Options) (*Store, error) {
+	db, err := bbolt.Open(path, 0600, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// NewStore wraps a DB that is already open. Closing the Store closes db.
+func NewStore(db *bbolt.DB) *Store {
+	return &Store{db: db}
+}
+
+// DB returns the underlying DB for code that runs its own transactions.
+func (s *Store) DB() *bbolt.DB {
+	return s.db
+}
+
+func (s *Store) View(fn func(tx *bbolt.Tx) error) error {
+	return s.db.View(fn)
+}
+
+func (s *Store) Update(fn func(tx *bbolt.Tx) error) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		hookCommit(tx)
+		return fn(tx)
+	})
+}
+
+// UpdateCtx is Update that gives up waiting once ctx is done and returns
+// ctx.Err(). bbolt transactions cannot be cancelled, so the update keeps
+// waiting for the write lock in the background and may still run and commit
+// after UpdateCtx has returned. fn must therefore be safe to run late, and
+// callers must not treat a context error as "nothing was written".
+func (s *Store) UpdateCtx(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	return runCtx(ctx, func() error {
+		return s.Update(fn)
+	})
+}
+
+// ViewCtx is View with the same early return as UpdateCtx.
+func (s *Store) ViewCtx(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	return runCtx(ctx, func() error {
+		return s.View(fn)
+	})
+}
+
+func runCtx(ctx context.Context, run func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogTxStats returns a commit hook that logs page and write stats at debug
+// level. It returns nil when logger has debug disabled, so installing it costs
+// nothing unless debug logging is on.
+func LogTxStats(logger *slog.Logger) func(stats bbolt.TxStats) {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return nil
+	}
+
+	return func(stats bbolt.TxStats) {
+		logger.Debug("bbolt update committed",
+			"pages", stats.GetPageCount(),
+			"page_bytes", stats.GetPageAlloc(),
+			"writes", stats.GetWrite(),
+			"write_time", stats.GetWriteTime(),
+		)
+	}
+}
+
+// Close closes the DB and releases its file lock.
+func (s *Store) Close() error {
+	nameCaches.Delete(s.db)
+	commitHooks.Delete(s.db)
+	codecs.Delete(s.db)
+	return s.db.Close()
+}
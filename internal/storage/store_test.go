@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestStoreLifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+
+	store, err := OpenStore(path, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sessionID uuid.UUID
+	if err := store.Update(func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "lifecycle")
+		sessionID = session.ID
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file lock must be released by Close, or this open times out.
+	store, err = OpenStore(path, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("reopen after close: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.View(func(tx *bbolt.Tx) error {
+		_, err := GetSession(tx, sessionID)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkspacesAreIndependent(t *testing.T) {
+	dir := t.TempDir()
+
+	open := func(workspace string) *Store {
+		path, err := WorkspacePath(dir, workspace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store, err := OpenStore(path, &bbolt.Options{Timeout: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+
+	work, personal := open("work"), open("personal")
+
+	if err := work.Update(func(tx *bbolt.Tx) error {
+		_, err := NewSession(tx, "deploy")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := personal.Update(func(tx *bbolt.Tx) error {
+		_, err := NewSession(tx, "deploy")
+		return err
+	}); err != nil {
+		t.Fatalf("expected the same name to be free in another workspace, got %v", err)
+	}
+
+	if err := personal.View(func(tx *bbolt.Tx) error {
+		sessions, err := GetSessions(tx)
+		if err != nil {
+			return err
+		}
+		if len(sessions) != 1 {
+			t.Fatalf("expected 1 session in personal workspace, got %d", len(sessions))
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := WorkspacePath(dir, "../escape"); !errors.Is(err, ErrInvalidWorkspace) {
+		t.Fatalf("expected ErrInvalidWorkspace, got %v", err)
+	}
+}
+
+func TestStoreCommitHook(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "hook.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var calls []bbolt.TxStats
+	store.SetCommitHook(func(stats bbolt.TxStats) {
+		calls = append(calls, stats)
+	})
+
+	if err := store.Update(func(tx *bbolt.Tx) error {
+		_, err := NewSession(tx, "hooked")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Update(func(tx *bbolt.Tx) error {
+		return errors.New("rolled back")
+	}); err == nil {
+		t.Fatal("expected failing update to return its error")
+	}
+
+	// Services write through the raw DB; their storage calls are hooked too,
+	// once per transaction however many writes it makes.
+	if err := store.DB().Update(func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "raw")
+		if err != nil {
+			return err
+		}
+		return UpdateSessionName(tx, session.ID, "raw-renamed")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunMigrations(store.DB()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 3 {
+		t.Fatalf("expected hook to run once per committed update, ran %d times", len(calls))
+	}
+	if calls[0].GetWrite() == 0 || calls[0].GetPageCount() == 0 {
+		t.Fatalf("expected non-zero write stats, got %+v", calls[0])
+	}
+}
+
+func TestLogTxStatsDisabledWithoutDebug(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	if LogTxStats(logger) != nil {
+		t.Fatal("expected no hook when debug logging is disabled")
+	}
+}
+
+func TestStoreUpdateCtxTimesOutOnHeldLock(t *testing.T) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "store.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	locked := make(chan struct{})
+	release := make(chan struct{})
+	held := make(chan error, 1)
+	go func() {
+		held <- store.Update(func(tx *bbolt.Tx) error {
+			close(locked)
+			<-release
+			return nil
+		})
+	}()
+	<-locked
+
+	ran := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = store.UpdateCtx(ctx, func(tx *bbolt.Tx) error {
+		close(ran)
+		_, err := NewSession(tx, "late")
+		return err
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+
+	// Once the lock is free the abandoned update still runs.
+	close(release)
+	if err := <-held; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("expected the abandoned update to run after the lock was released")
+	}
+}
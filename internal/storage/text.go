@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+var ErrInvalidText = errors.New("invalid text: must be valid UTF-8 without control characters and within the length limit")
+
+const (
+	MaxDescriptionLength = 256
+	MaxTitleLength       = 128
+)
+
+// sanitizeText checks a free-text field before it is stored. It rejects
+// invalid UTF-8, which covers unpaired surrogates, control characters other
+// than tab, and strings longer than max runes.
+func sanitizeText(s string, max int) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", ErrInvalidText
+	}
+
+	if utf8.RuneCountInString(s) > max {
+		return "", ErrInvalidText
+	}
+
+	for _, r := range s {
+		if r != '\t' && unicode.IsControl(r) {
+			return "", ErrInvalidText
+		}
+	}
+
+	return s, nil
+}
+
+func UpdateSessionDescription(tx *bbolt.Tx, id uuid.UUID, description string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	description, err := sanitizeText(description, MaxDescriptionLength)
+	if err != nil {
+		return err
+	}
+
+	session, err := GetSession(tx, id)
+	if err != nil {
+		return err
+	}
+
+	session.Description, session.UpdatedAt = description, now()
+
+	return putSession(tx, session)
+}
+
+func UpdatePaneTitle(tx *bbolt.Tx, sessionId, windowId uuid.UUID, id uuid.UUID, title string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	title, err := sanitizeText(title, MaxTitleLength)
+	if err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	pane, err := GetPane(tx, sessionId, window.ID, id)
+	if err != nil {
+		return err
+	}
+
+	pane.Title, pane.UpdatedAt = title, now()
+
+	return putPane(tx, pane)
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestSanitizeText(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"control character", "build\x1b[31m", false},
+		{"over length", strings.Repeat("a", MaxTitleLength+1), false},
+		{"invalid utf8", "bad\xed\xa0\x80", false},
+		{"tab", "col\tcol", true},
+		{"unicode", "déploiement 🚀 日本語", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := sanitizeText(tc.input, MaxTitleLength)
+			if tc.ok && err != nil {
+				t.Fatalf("expected %q to be accepted, got %v", tc.input, err)
+			}
+			if !tc.ok && !errors.Is(err, ErrInvalidText) {
+				t.Fatalf("expected ErrInvalidText for %q, got %v", tc.input, err)
+			}
+		})
+	}
+}
+
+func TestUpdatePaneTitle(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := UpdatePaneTitle(tx, sessionID, windowID, pane.ID, "logs\n"); !errors.Is(err, ErrInvalidText) {
+			t.Fatalf("expected ErrInvalidText, got %v", err)
+		}
+		if err := UpdatePaneTitle(tx, sessionID, windowID, pane.ID, "логи"); err != nil {
+			t.Fatal(err)
+		}
+
+		pane, err = GetPane(tx, sessionID, windowID, pane.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pane.Title != "логи" {
+			t.Fatalf("expected title to be stored, got %q", pane.Title)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// NormalizeTimestamps sets every zero CreatedAt or UpdatedAt of a session,
+// window or pane to the current time, for data written by older builds or
+// imported by hand. It returns how many entries were rewritten. Missing
+// buckets are skipped.
+func NormalizeTimestamps(tx *bbolt.Tx) (int, error) {
+	if err := writableTx(tx); err != nil {
+		return 0, err
+	}
+
+	at := now()
+	fixed := 0
+
+	if bucket := tx.Bucket(SessionBucket); bucket != nil {
+		// A session's creation time is part of its __session_by_created__ key,
+		// so each fixed CreatedAt has to move its index entry too.
+		var reindex []SessionEntry
+
+		n, err := normalizeBucket(bucket, SessionKind, func(session *SessionEntry) bool {
+			if session.CreatedAt.IsZero() {
+				reindex = append(reindex, *session)
+			}
+			return fillZeroTimes(&session.CreatedAt, &session.UpdatedAt, at)
+		})
+		fixed += n
+		if err != nil {
+			return fixed, err
+		}
+
+		for _, session := range reindex {
+			if err := unindexSessionCreated(bucket, session); err != nil {
+				return fixed, err
+			}
+
+			session.CreatedAt = at
+			if err := indexSessionCreated(bucket, session); err != nil {
+				return fixed, err
+			}
+		}
+	}
+
+	nested := []struct {
+		name []byte
+		fix  func(*bbolt.Bucket) (int, error)
+	}{
+		{WindowBucket, func(bucket *bbolt.Bucket) (int, error) {
+			return normalizeBucket(bucket, WindowKind, func(window *WindowEntry) bool {
+				return fillZeroTimes(&window.CreatedAt, &window.UpdatedAt, at)
+			})
+		}},
+		{PaneBucket, func(bucket *bbolt.Bucket) (int, error) {
+			return normalizeBucket(bucket, PaneKind, func(pane *PaneEntry) bool {
+				return fillZeroTimes(&pane.CreatedAt, &pane.UpdatedAt, at)
+			})
+		}},
+	}
+
+	for _, top := range nested {
+		parent := tx.Bucket(top.name)
+		if parent == nil {
+			continue
+		}
+
+		// Windows and panes live one level down, per session and per window.
+		var children [][]byte
+		if err := parent.ForEach(func(k, v []byte) error {
+			if v == nil {
+				children = append(children, k)
+			}
+			return nil
+		}); err != nil {
+			return fixed, err
+		}
+
+		for _, key := range children {
+			n, err := top.fix(parent.Bucket(key))
+			fixed += n
+			if err != nil {
+				return fixed, err
+			}
+		}
+	}
+
+	return fixed, nil
+}
+
+// normalizeBucket decodes every entry of bucket, skipping nested buckets, and
+// writes back the ones fix changed.
+func normalizeBucket[T any](bucket *bbolt.Bucket, kind EntryKind, fix func(*T) bool) (int, error) {
+	type update struct {
+		key, value []byte
+	}
+
+	var updates []update
+
+	// bbolt does not allow writes while ForEach runs, so collect them first.
+	if err := bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+
+		var entry T
+		if err := unmarshalEntry(bucket.Tx(), kind, v, &entry); err != nil {
+			return err
+		}
+
+		if !fix(&entry) {
+			return nil
+		}
+
+		data, err := marshalEntry(bucket.Tx(), kind, entry)
+		if err != nil {
+			return err
+		}
+
+		updates = append(updates, update{key: append([]byte(nil), k...), value: data})
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for i, u := range updates {
+		if err := bucket.Put(u.key, u.value); err != nil {
+			return i, err
+		}
+	}
+
+	return len(updates), nil
+}
+
+func fillZeroTimes(createdAt, updatedAt *time.Time, at time.Time) bool {
+	changed := false
+
+	if createdAt.IsZero() {
+		*createdAt, changed = at, true
+	}
+	if updatedAt.IsZero() {
+		*updatedAt, changed = at, true
+	}
+
+	return changed
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+func TestNormalizeTimestamps(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "stale")
+
+	var window WindowEntry
+	var pane PaneEntry
+
+	// Zero the timestamps of the session, one window and one of its panes.
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := GetSession(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		session.CreatedAt, session.UpdatedAt = time.Time{}, time.Time{}
+		if err := putSession(tx, session); err != nil {
+			return err
+		}
+
+		if window, err = GetWindowByIndex(tx, sessionID, 0); err != nil {
+			return err
+		}
+		window.CreatedAt = time.Time{}
+		if err := putWindow(tx, window); err != nil {
+			return err
+		}
+
+		panes, err := GetPanes(tx, sessionID, window.ID)
+		if err != nil {
+			return err
+		}
+		pane = panes[0]
+		pane.UpdatedAt = time.Time{}
+		return putPane(tx, pane)
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		fixed, err := NormalizeTimestamps(tx)
+		if err != nil {
+			return err
+		}
+		if fixed != 3 {
+			t.Fatalf("expected 3 entries fixed, got %d", fixed)
+		}
+
+		session, err := GetSession(tx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.CreatedAt.IsZero() || session.UpdatedAt.IsZero() {
+			t.Fatalf("expected the session's timestamps to be filled, got %+v", session)
+		}
+
+		storedWindow, err := GetWindow(tx, sessionID, window.ID)
+		if err != nil {
+			return err
+		}
+		if storedWindow.CreatedAt.IsZero() || !storedWindow.UpdatedAt.Equal(window.UpdatedAt) {
+			t.Fatalf("expected only the window's CreatedAt to change, got %+v", storedWindow)
+		}
+
+		storedPane, err := GetPane(tx, sessionID, window.ID, pane.ID)
+		if err != nil {
+			return err
+		}
+		if storedPane.UpdatedAt.IsZero() {
+			t.Fatalf("expected the pane's UpdatedAt to be filled, got %+v", storedPane)
+		}
+
+		again, err := NormalizeTimestamps(tx)
+		if err != nil {
+			return err
+		}
+		if again != 0 {
+			t.Fatalf("expected a second pass to fix nothing, got %d", again)
+		}
+		return nil
+	})
+}
+
+func TestNormalizeTimestampsReindexesSessions(t *testing.T) {
+	db := openTestDB(t)
+
+	var stale, fresh SessionEntry
+
+	// Store stale as an older build would have: a zero CreatedAt, indexed
+	// under the zero time.
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		if stale, err = NewSession(tx, "stale"); err != nil {
+			return err
+		}
+		if fresh, err = NewSession(tx, "fresh"); err != nil {
+			return err
+		}
+
+		bucket := tx.Bucket(SessionBucket)
+		if err := unindexSessionCreated(bucket, stale); err != nil {
+			return err
+		}
+		stale.CreatedAt = time.Time{}
+		if err := indexSessionCreated(bucket, stale); err != nil {
+			return err
+		}
+		return putSession(tx, stale)
+	})
+
+	sortedIDs := func(tx *bbolt.Tx) []string {
+		t.Helper()
+		sessions, err := GetSessionsSorted(tx, ByCreatedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(sessions))
+		for i, session := range sessions {
+			ids[i] = session.ID.String()
+		}
+		return ids
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := NormalizeTimestamps(tx); err != nil {
+			return err
+		}
+
+		// stale now counts as created last.
+		if got := sortedIDs(tx); len(got) != 2 || got[0] != fresh.ID.String() || got[1] != stale.ID.String() {
+			t.Fatalf("expected [fresh stale] after normalizing, got %v", got)
+		}
+
+		return DeleteSession(tx, stale.ID)
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if got := sortedIDs(tx); len(got) != 1 || got[0] != fresh.ID.String() {
+			t.Fatalf("expected [fresh] after deleting stale, got %v", got)
+		}
+		return nil
+	})
+}
@@ -18,9 +18,12 @@ package storage
 //   - Windows are tied to sessions; deleting a session should remove its windows.
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -32,22 +35,82 @@ var (
 	ErrWindowNotFound              = errors.New("window not found")
 	ErrWindowBucketNotFound        = errors.New("window bucket now found")
 	ErrWindowSessionBucketNotFound = errors.New("window session bucket not found")
+	ErrWindowLimitReached          = errors.New("window limit reached for session")
+	ErrWindowIndexCorrupt          = errors.New("window indices are not a contiguous 0..n-1 sequence")
+	ErrWindowAlreadyExists         = errors.New("window with the id already exists")
+	ErrEmptyWindowName             = errors.New("empty window name")
+	ErrNameGenerationFailed        = errors.New("could not generate a unique window name")
+	ErrWindowNotEmpty              = errors.New("window still has panes")
+	ErrIndexOutOfRange             = errors.New("window index out of range")
 )
 
-var windowBucketName = []byte("WINDOW")
+// WindowBucket holds one sub-bucket per session ID with its windows keyed by
+// ID.
+var WindowBucket = []byte("WINDOW")
+
+// MaxWindowsPerSession caps how many windows a session may hold. Zero means
+// unlimited.
+var MaxWindowsPerSession = 0
+
+// WindowNameLength is the length of the random suffix of generated window
+// names.
+var WindowNameLength = 8
+
+// windowNameAttempts bounds how often NewWindow regenerates a name that is
+// already taken in the session.
+const windowNameAttempts = 5
+
+// WindowNameAlphabet is the alphabet of the random suffix of generated window
+// names. Names end up in logs and URLs, so it may only use A-Z, a-z, 0-9, _
+// and -; anything else fails NewWindow with ErrInvalidWindowNameAlphabet.
+var WindowNameAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_-"
+
+var ErrInvalidWindowNameAlphabet = errors.New("invalid window name alphabet: must be at least 2 of A-Z, a-z, 0-9, _, -")
+
+var windowNameAlphabetPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{2,}$`)
+
+var generateWindowName = func() (string, error) {
+	if !windowNameAlphabetPattern.MatchString(WindowNameAlphabet) {
+		return "", ErrInvalidWindowNameAlphabet
+	}
+
+	id, err := nanoid.Generate(WindowNameAlphabet, WindowNameLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Window-%s", id), nil
+}
 
 type WindowEntry struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Index     int       `json:"index"`
-	SessionID uuid.UUID `json:"sessionId"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	SchemaVersion int       `json:"schemaVersion"`
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Index         int       `json:"index"`
+	// Seq is the window's creation order within its session. Unlike Index it
+	// never changes once assigned. Windows written before Seq existed have 0.
+	Seq          int64     `json:"seq,omitempty"`
+	SessionID    uuid.UUID `json:"sessionId"`
+	HasActivity  bool      `json:"hasActivity"`
+	Cwd          string    `json:"cwd,omitempty"`
+	LastActiveAt time.Time `json:"lastActiveAt"`
+	// FocusHistory lists the panes focused with SetActivePane, most recent
+	// last and without repeats. It holds at most MaxFocusHistory entries.
+	FocusHistory []uuid.UUID `json:"focusHistory,omitempty"`
+	// ZoomedPaneID is the pane zoomed with SetPaneZoom, or uuid.Nil.
+	ZoomedPaneID uuid.UUID `json:"zoomedPaneId"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
 func NewWindow(tx *bbolt.Tx, sessionId uuid.UUID) (WindowEntry, error) {
-	if tx == nil {
-		return WindowEntry{}, ErrTxnNotFound
+	return newWindow(tx, sessionId, "")
+}
+
+// newWindow is NewWindow with a given name; an empty name is generated.
+func newWindow(tx *bbolt.Tx, sessionId uuid.UUID, name string) (WindowEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return WindowEntry{}, err
 	}
 
 	session, err := GetSession(tx, sessionId)
@@ -55,7 +118,11 @@ func NewWindow(tx *bbolt.Tx, sessionId uuid.UUID) (WindowEntry, error) {
 		return WindowEntry{}, err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(windowBucketName)
+	if err := checkNotTerminated(session); err != nil {
+		return WindowEntry{}, err
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(WindowBucket)
 	if err != nil {
 		return WindowEntry{}, err
 	}
@@ -65,25 +132,38 @@ func NewWindow(tx *bbolt.Tx, sessionId uuid.UUID) (WindowEntry, error) {
 		return WindowEntry{}, err
 	}
 
-	stats := sessionBucket.Stats()
-	index := stats.KeyN
+	existing, err := scanWindows(sessionBucket)
+	if err != nil {
+		return WindowEntry{}, err
+	}
 
-	id, err := nanoid.Generate("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_-", 8)
+	if MaxWindowsPerSession > 0 && existing.count >= MaxWindowsPerSession {
+		return WindowEntry{}, ErrWindowLimitReached
+	}
+
+	if name == "" {
+		if name, err = uniqueWindowName(existing.names); err != nil {
+			return WindowEntry{}, err
+		}
+	}
+
+	seq, err := sessionBucket.NextSequence()
 	if err != nil {
 		return WindowEntry{}, err
 	}
-	name := fmt.Sprintf("Window-%s", id)
 
 	window := WindowEntry{
-		ID:        uuid.New(),
-		Name:      name,
-		Index:     index,
-		SessionID: session.ID,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		SchemaVersion: CurrentSchemaVersion,
+		ID:            uuid.New(),
+		Name:          name,
+		Index:         existing.nextIndex,
+		Seq:           int64(seq),
+		SessionID:     session.ID,
+		CreatedAt:     now(),
+		UpdatedAt:     now(),
 	}
 
-	bytes, err := json.Marshal(window)
+	bytes, err := marshalEntry(tx, WindowKind, window)
 	if err != nil {
 		return WindowEntry{}, err
 	}
@@ -92,9 +172,121 @@ func NewWindow(tx *bbolt.Tx, sessionId uuid.UUID) (WindowEntry, error) {
 		return WindowEntry{}, err
 	}
 
+	publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowCreated, Window: window})
+
 	return window, nil
 }
 
+// NewWindowWithPane creates a window holding one pane at the origin. A zero
+// width or height takes the session's DefaultSize.
+func NewWindowWithPane(tx *bbolt.Tx, sessionId uuid.UUID, width, height int32) (WindowEntry, PaneEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return WindowEntry{}, PaneEntry{}, err
+	}
+
+	session, err := GetSession(tx, sessionId)
+	if err != nil {
+		return WindowEntry{}, PaneEntry{}, err
+	}
+
+	if width == 0 || height == 0 {
+		defaultWidth, defaultHeight := session.DefaultSize()
+		if width == 0 {
+			width = defaultWidth
+		}
+		if height == 0 {
+			height = defaultHeight
+		}
+	}
+
+	window, err := NewWindow(tx, session.ID)
+	if err != nil {
+		return WindowEntry{}, PaneEntry{}, err
+	}
+
+	pane, err := NewPane(tx, session.ID, window.ID, width, height, 0, 0, "")
+	if err != nil {
+		return WindowEntry{}, PaneEntry{}, err
+	}
+
+	return window, pane, nil
+}
+
+// NewWindowAt creates a window at index and shifts the windows from index on
+// up by one. index must be within 0..count, where count appends like
+// NewWindow.
+func NewWindowAt(tx *bbolt.Tx, sessionId uuid.UUID, index int) (WindowEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return WindowEntry{}, err
+	}
+
+	count, err := CountWindows(tx, sessionId)
+	if err != nil {
+		return WindowEntry{}, err
+	}
+
+	if index < 0 || index > count {
+		return WindowEntry{}, fmt.Errorf("%w: %d is not within 0..%d", ErrIndexOutOfRange, index, count)
+	}
+
+	window, err := NewWindow(tx, sessionId)
+	if err != nil {
+		return WindowEntry{}, err
+	}
+
+	if err := MoveWindow(tx, sessionId, window.ID, index); err != nil {
+		return WindowEntry{}, err
+	}
+
+	return GetWindow(tx, sessionId, window.ID)
+}
+
+// windowScan is what NewWindow needs to know about a session's windows.
+type windowScan struct {
+	names map[string]bool
+	// nextIndex is one past the highest Index, or 0 without windows. Deletes
+	// can leave gaps, so the count is not safe to use instead.
+	nextIndex int
+	count     int
+}
+
+// scanWindows decodes every window in the session bucket once.
+func scanWindows(sessionBucket *bbolt.Bucket) (windowScan, error) {
+	scan := windowScan{names: make(map[string]bool)}
+
+	if err := sessionBucket.ForEach(func(k, v []byte) error {
+		var window WindowEntry
+		if err := unmarshalEntry(sessionBucket.Tx(), WindowKind, v, &window); err != nil {
+			return err
+		}
+		scan.names[window.Name] = true
+		scan.nextIndex = max(scan.nextIndex, window.Index+1)
+		scan.count++
+		return nil
+	}); err != nil {
+		return windowScan{}, err
+	}
+
+	return scan, nil
+}
+
+// uniqueWindowName generates names until one is not in taken, giving up after
+// windowNameAttempts tries.
+func uniqueWindowName(taken map[string]bool) (string, error) {
+	for range windowNameAttempts {
+		name, err := generateWindowName()
+		if err != nil {
+			return "", err
+		}
+
+		if !taken[name] {
+			return name, nil
+		}
+	}
+
+	return "", ErrNameGenerationFailed
+}
+
 func GetWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (WindowEntry, error) {
 	if tx == nil {
 		return WindowEntry{}, ErrTxnNotFound
@@ -105,7 +297,7 @@ func GetWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (WindowEntry, error)
 		return WindowEntry{}, err
 	}
 
-	bucket := tx.Bucket(windowBucketName)
+	bucket := tx.Bucket(WindowBucket)
 	if bucket == nil {
 		return WindowEntry{}, ErrWindowBucketNotFound
 	}
@@ -121,14 +313,107 @@ func GetWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (WindowEntry, error)
 	}
 
 	var window WindowEntry
-	if err := json.Unmarshal(entry, &window); err != nil {
+	if err := unmarshalEntry(tx, WindowKind, entry, &window); err != nil {
 		return WindowEntry{}, err
 	}
 
 	return window, nil
 }
 
+// GetWindowByIndex returns the window at a stored index (not a display
+// number), or ErrWindowNotFound.
+func GetWindowByIndex(tx *bbolt.Tx, sessionId uuid.UUID, index int) (WindowEntry, error) {
+	windows, err := GetWindows(tx, sessionId)
+	if errors.Is(err, ErrWindowBucketNotFound) || errors.Is(err, ErrWindowSessionBucketNotFound) {
+		return WindowEntry{}, ErrWindowNotFound
+	} else if err != nil {
+		return WindowEntry{}, err
+	}
+
+	for _, window := range windows {
+		if window.Index == index {
+			return window, nil
+		}
+	}
+
+	return WindowEntry{}, ErrWindowNotFound
+}
+
+// CloneWindow duplicates a window and its panes at the end of the same
+// session. The copy gets fresh UUIDs and its name is the original name with a
+// " (copy)" suffix; pane geometry and cwd are preserved.
+func CloneWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID) (WindowEntry, error) {
+	return cloneWindow(tx, sessionId, windowId, sessionId, " (copy)")
+}
+
+// CloneWindowToSession is CloneWindow into another existing session. The
+// source is left in place and the copy keeps the original name.
+func CloneWindowToSession(tx *bbolt.Tx, srcSessionId, windowId, dstSessionId uuid.UUID) (WindowEntry, error) {
+	return cloneWindow(tx, srcSessionId, windowId, dstSessionId, "")
+}
+
+func cloneWindow(tx *bbolt.Tx, srcSessionId, windowId, dstSessionId uuid.UUID, suffix string) (WindowEntry, error) {
+	if err := writableTx(tx); err != nil {
+		return WindowEntry{}, err
+	}
+
+	source, err := GetWindow(tx, srcSessionId, windowId)
+	if err != nil {
+		return WindowEntry{}, err
+	}
+
+	panes, err := GetPanes(tx, srcSessionId, source.ID)
+	if errors.Is(err, ErrPaneBucketNotFound) || errors.Is(err, ErrPaneWindowBucketNotFound) {
+		panes = nil
+	} else if err != nil {
+		return WindowEntry{}, err
+	}
+
+	// Named up front so the WindowCreated event carries the final name.
+	clone, err := newWindow(tx, dstSessionId, source.Name+suffix)
+	if err != nil {
+		return WindowEntry{}, err
+	}
+
+	for _, pane := range panes {
+		if _, err := NewPane(tx, dstSessionId, clone.ID, pane.Width, pane.Height, pane.X, pane.Y, pane.Cwd); err != nil {
+			return WindowEntry{}, err
+		}
+	}
+
+	return clone, nil
+}
+
+// CountWindows returns how many windows a session has.
+func CountWindows(tx *bbolt.Tx, sessionId uuid.UUID) (int, error) {
+	if tx == nil {
+		return 0, ErrTxnNotFound
+	}
+
+	session, err := GetSession(tx, sessionId)
+	if err != nil {
+		return 0, err
+	}
+
+	bucket := tx.Bucket(WindowBucket)
+	if bucket == nil {
+		return 0, nil
+	}
+
+	sessionBucket := bucket.Bucket([]byte(session.ID.String()))
+	if sessionBucket == nil {
+		return 0, nil
+	}
+
+	return countKeys(sessionBucket), nil
+}
+
+// GetWindows returns the session's windows, or an empty slice if it has none.
 func GetWindows(tx *bbolt.Tx, sessionId uuid.UUID) ([]WindowEntry, error) {
+	return getWindows(tx, sessionId, nil)
+}
+
+func getWindows(tx *bbolt.Tx, sessionId uuid.UUID, corrupt *[]CorruptRecord) ([]WindowEntry, error) {
 	if tx == nil {
 		return nil, ErrTxnNotFound
 	}
@@ -138,14 +423,21 @@ func GetWindows(tx *bbolt.Tx, sessionId uuid.UUID) ([]WindowEntry, error) {
 		return nil, err
 	}
 
-	bucket := tx.Bucket(windowBucketName)
+	bucket := tx.Bucket(WindowBucket)
 	if bucket == nil {
-		return nil, ErrWindowBucketNotFound
+		return make([]WindowEntry, 0), nil
 	}
 
-	sessionBucket := bucket.Bucket([]byte(session.ID.String()))
+	key := []byte(session.ID.String())
+
+	sessionBucket := bucket.Bucket(key)
 	if sessionBucket == nil {
-		return nil, ErrWindowSessionBucketNotFound
+		// A session without windows has no bucket; anything else stored
+		// under its key means the window bucket is damaged.
+		if bucket.Get(key) != nil {
+			return nil, ErrWindowSessionBucketNotFound
+		}
+		return make([]WindowEntry, 0), nil
 	}
 
 	stats := sessionBucket.Stats()
@@ -154,8 +446,8 @@ func GetWindows(tx *bbolt.Tx, sessionId uuid.UUID) ([]WindowEntry, error) {
 
 	if err = sessionBucket.ForEach(func(k, v []byte) error {
 		var window WindowEntry
-		if err = json.Unmarshal(v, &window); err != nil {
-			return err
+		if err := unmarshalEntry(tx, WindowKind, v, &window); err != nil {
+			return skipCorrupt(corrupt, k, err)
 		}
 
 		windows = append(windows, window)
@@ -167,36 +459,399 @@ func GetWindows(tx *bbolt.Tx, sessionId uuid.UUID) ([]WindowEntry, error) {
 	return windows, nil
 }
 
-func DeleteWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID) error {
-	if tx == nil {
-		return ErrTxnNotFound
+// GetWindowsByCreation returns a session's windows in the order they were
+// created, regardless of how they have been reordered since. Windows without
+// a Seq sort first, by CreatedAt.
+func GetWindowsByCreation(tx *bbolt.Tx, sessionId uuid.UUID) ([]WindowEntry, error) {
+	windows, err := GetWindows(tx, sessionId)
+	if err != nil {
+		return nil, err
 	}
 
-	session, err := GetSession(tx, sessionId)
+	sort.SliceStable(windows, func(i, j int) bool {
+		if windows[i].Seq != windows[j].Seq {
+			return windows[i].Seq < windows[j].Seq
+		}
+		return windows[i].CreatedAt.Before(windows[j].CreatedAt)
+	})
+
+	return windows, nil
+}
+
+// reserveWindowSeq makes sure later NewWindow calls in the session are
+// assigned a Seq above seq. ImportSession uses it for windows that keep the
+// Seq from their export.
+func reserveWindowSeq(tx *bbolt.Tx, sessionId uuid.UUID, seq int64) error {
+	bucket := tx.Bucket(WindowBucket)
+	if bucket == nil {
+		return ErrWindowBucketNotFound
+	}
+
+	sessionBucket := bucket.Bucket([]byte(sessionId.String()))
+	if sessionBucket == nil {
+		return ErrWindowSessionBucketNotFound
+	}
+
+	if seq > 0 && uint64(seq) > sessionBucket.Sequence() {
+		return sessionBucket.SetSequence(uint64(seq))
+	}
+
+	return nil
+}
+
+// WindowWithCount is a window along with how many panes it has.
+type WindowWithCount struct {
+	WindowEntry
+	PaneCount int
+}
+
+// GetWindowsWithCounts is GetWindows ordered by Index, with each window's pane
+// count read from its PANE sub-bucket. Panes are counted with a cursor since
+// bucket stats miss writes made earlier in the same transaction.
+func GetWindowsWithCounts(tx *bbolt.Tx, sessionId uuid.UUID) ([]WindowWithCount, error) {
+	windows, err := GetWindows(tx, sessionId)
 	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Index < windows[j].Index
+	})
+
+	panes := tx.Bucket(PaneBucket)
+	counted := make([]WindowWithCount, 0, len(windows))
+
+	for _, window := range windows {
+		entry := WindowWithCount{WindowEntry: window}
+
+		if panes != nil {
+			if windowBucket := panes.Bucket([]byte(window.ID.String())); windowBucket != nil {
+				entry.PaneCount = countKeys(windowBucket)
+			}
+		}
+
+		counted = append(counted, entry)
+	}
+
+	return counted, nil
+}
+
+// checkWindowIndices verifies that the windows of a session are numbered
+// 0..n-1 with no negative, missing or duplicate index.
+func checkWindowIndices(tx *bbolt.Tx, sessionId uuid.UUID) error {
+	windows, err := GetWindows(tx, sessionId)
+	if errors.Is(err, ErrWindowBucketNotFound) || errors.Is(err, ErrWindowSessionBucketNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	seen := make([]bool, len(windows))
+
+	for _, window := range windows {
+		if window.Index < 0 || window.Index >= len(windows) || seen[window.Index] {
+			return fmt.Errorf("%w: window %s has index %d", ErrWindowIndexCorrupt, window.ID, window.Index)
+		}
+		seen[window.Index] = true
+	}
+
+	return nil
+}
+
+// ReindexWindows renumbers a session's windows to 0..n-1, keeping their
+// current relative order. It closes the gaps left behind by deletes.
+func ReindexWindows(tx *bbolt.Tx, sessionId uuid.UUID) error {
+	if err := writableTx(tx); err != nil {
 		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(windowBucketName)
+	windows, err := GetWindows(tx, sessionId)
 	if err != nil {
 		return err
 	}
 
-	sessionBucket, err := bucket.CreateBucketIfNotExists([]byte(session.ID.String()))
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Index < windows[j].Index
+	})
+
+	for i, window := range windows {
+		if window.Index == i {
+			continue
+		}
+
+		window.Index, window.UpdatedAt = i, now()
+
+		if err := putWindow(tx, window); err != nil {
+			return err
+		}
+
+		publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowMoved, Window: window})
+	}
+
+	if debugInvariants {
+		return checkWindowIndices(tx, sessionId)
+	}
+
+	return nil
+}
+
+// RenameWindow sets a window's display name. Names are trimmed and follow the
+// same rules as other free text.
+func RenameWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID, name string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrEmptyWindowName
+	}
+
+	name, err := sanitizeText(name, MaxTitleLength)
 	if err != nil {
 		return err
 	}
 
-	if err := sessionBucket.Delete([]byte(windowId.String())); err != nil {
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
 		return err
 	}
 
+	if window.Name == name {
+		return nil
+	}
+
+	window.Name, window.UpdatedAt = name, now()
+
+	if err := putWindow(tx, window); err != nil {
+		return err
+	}
+
+	publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowRenamed, Window: window})
+
 	return nil
 }
 
-func DeleteWindows(tx *bbolt.Tx, sessionId uuid.UUID) error {
+// MoveWindow moves a window to newIndex, shifting the windows between its old
+// and new position by one. newIndex must be within 0..n-1 for a session with n
+// windows; anything else fails with an error wrapping ErrIndexOutOfRange that
+// names the valid range. Every window whose index changes is published as
+// WindowMoved.
+func MoveWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID, newIndex int) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	windows, err := GetWindows(tx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windows[i].Index < windows[j].Index
+	})
+
+	ordered := make([]WindowEntry, 0, len(windows))
+	for _, w := range windows {
+		if w.ID != window.ID {
+			ordered = append(ordered, w)
+		}
+	}
+
+	if newIndex < 0 || newIndex >= len(windows) {
+		return fmt.Errorf("%w: %d is not within 0..%d", ErrIndexOutOfRange, newIndex, len(windows)-1)
+	}
+
+	ordered = slices.Insert(ordered, newIndex, window)
+
+	for i, w := range ordered {
+		if w.Index == i {
+			continue
+		}
+
+		w.Index, w.UpdatedAt = i, now()
+
+		if err := putWindow(tx, w); err != nil {
+			return err
+		}
+
+		publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowMoved, Window: w})
+	}
+
+	if debugInvariants {
+		return checkWindowIndices(tx, sessionId)
+	}
+
+	return nil
+}
+
+func putWindow(tx *bbolt.Tx, window WindowEntry) error {
+	bucket, err := tx.CreateBucketIfNotExists(WindowBucket)
+	if err != nil {
+		return err
+	}
+
+	sessionBucket, err := bucket.CreateBucketIfNotExists([]byte(window.SessionID.String()))
+	if err != nil {
+		return err
+	}
+
+	bytes, err := marshalEntry(tx, WindowKind, window)
+	if err != nil {
+		return err
+	}
+
+	return sessionBucket.Put([]byte(window.ID.String()), bytes)
+}
+
+// MarkWindowActivity flags a window as having produced output while it was
+// not being looked at. The session's active window is never flagged.
+func MarkWindowActivity(tx *bbolt.Tx, sessionId, windowId uuid.UUID) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	session, err := GetSession(tx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	if session.ActiveWindowID == window.ID || window.HasActivity {
+		return nil
+	}
+
+	window.HasActivity, window.UpdatedAt = true, now()
+
+	if err := putWindow(tx, window); err != nil {
+		return err
+	}
+
+	publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowActivity, Window: window})
+
+	return nil
+}
+
+// UpdateWindowCwd sets the directory new panes in the window start in when
+// they are created without one. An empty cwd falls back to the session's.
+func UpdateWindowCwd(tx *bbolt.Tx, sessionId, windowId uuid.UUID, cwd string) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	cwd, err := normalizeCwd(cwd)
+	if err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	window.Cwd, window.UpdatedAt = cwd, now()
+
+	return putWindow(tx, window)
+}
+
+// SetActiveWindow makes a window the session's current window and clears its
+// activity flag.
+func SetActiveWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	session, err := GetSession(tx, sessionId)
+	if err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	hadActivity := window.HasActivity
+
+	window.HasActivity = false
+	window.LastActiveAt, window.UpdatedAt = now(), now()
+
+	if err := putWindow(tx, window); err != nil {
+		return err
+	}
+
+	if hadActivity {
+		publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowActivity, Window: window})
+	}
+
+	session.ActiveWindowID, session.UpdatedAt = window.ID, now()
+
+	return putSession(tx, session)
+}
+
+// GetLastActiveWindow returns the most recently active window other than the
+// session's current one, for toggling back to it. Windows that were never
+// made active do not count; ErrWindowNotFound is returned if there are none.
+func GetLastActiveWindow(tx *bbolt.Tx, sessionId uuid.UUID) (WindowEntry, error) {
 	if tx == nil {
-		return ErrTxnNotFound
+		return WindowEntry{}, ErrTxnNotFound
+	}
+
+	session, err := GetSession(tx, sessionId)
+	if err != nil {
+		return WindowEntry{}, err
+	}
+
+	windows, err := GetWindows(tx, session.ID)
+	if errors.Is(err, ErrWindowBucketNotFound) || errors.Is(err, ErrWindowSessionBucketNotFound) {
+		return WindowEntry{}, ErrWindowNotFound
+	} else if err != nil {
+		return WindowEntry{}, err
+	}
+
+	var last WindowEntry
+	found := false
+
+	for _, window := range windows {
+		if window.ID == session.ActiveWindowID || window.LastActiveAt.IsZero() {
+			continue
+		}
+
+		if !found || window.LastActiveAt.After(last.LastActiveAt) {
+			last, found = window, true
+		}
+	}
+
+	if !found {
+		return WindowEntry{}, ErrWindowNotFound
+	}
+
+	return last, nil
+}
+
+// DeleteWindow removes a window along with all of its panes.
+func DeleteWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID) error {
+	return deleteWindow(tx, sessionId, windowId, true)
+}
+
+// DeleteWindowIfEmpty is DeleteWindow that refuses with ErrWindowNotEmpty
+// while the window still has panes.
+func DeleteWindowIfEmpty(tx *bbolt.Tx, sessionId, windowId uuid.UUID) error {
+	return deleteWindow(tx, sessionId, windowId, false)
+}
+
+func deleteWindow(tx *bbolt.Tx, sessionId, windowId uuid.UUID, force bool) error {
+	if err := writableTx(tx); err != nil {
+		return err
 	}
 
 	session, err := GetSession(tx, sessionId)
@@ -204,35 +859,96 @@ func DeleteWindows(tx *bbolt.Tx, sessionId uuid.UUID) error {
 		return err
 	}
 
-	bucket, err := tx.CreateBucketIfNotExists(windowBucketName)
+	bucket, err := bucketForWrite(tx, WindowBucket, ErrWindowBucketNotFound)
 	if err != nil {
 		return err
 	}
 
-	sessionBucket, err := bucket.CreateBucketIfNotExists([]byte(session.ID.String()))
+	sessionBucket, err := subBucketForWrite(bucket, []byte(session.ID.String()), ErrWindowSessionBucketNotFound)
+	if err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, session.ID, windowId)
 	if err != nil {
 		return err
 	}
 
+	if !force {
+		count, err := CountPanes(tx, session.ID, window.ID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrWindowNotEmpty
+		}
+	}
+
+	if _, err := deletePaneBucket(tx, window); err != nil {
+		return err
+	}
+
+	if err := sessionBucket.Delete([]byte(window.ID.String())); err != nil {
+		return err
+	}
+
+	publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowDeleted, Window: window})
+
+	return nil
+}
+
+func DeleteWindows(tx *bbolt.Tx, sessionId uuid.UUID) error {
+	_, _, err := deleteWindows(tx, sessionId)
+	return err
+}
+
+// deleteWindows removes every window of a session along with their panes and
+// returns how many of each were deleted.
+func deleteWindows(tx *bbolt.Tx, sessionId uuid.UUID) (windows, panes int, err error) {
+	if err := writableTx(tx); err != nil {
+		return 0, 0, err
+	}
+
+	session, err := GetSession(tx, sessionId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bucket, err := tx.CreateBucketIfNotExists(WindowBucket)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	sessionBucket, err := bucket.CreateBucketIfNotExists([]byte(session.ID.String()))
+	if err != nil {
+		return 0, 0, err
+	}
+
 	if err := sessionBucket.ForEach(func(k, v []byte) error {
 		var window WindowEntry
 
-		if err := json.Unmarshal(v, &window); err != nil {
+		if err := unmarshalEntry(tx, WindowKind, v, &window); err != nil {
 			return err
 		}
 
-		if err := DeletePanes(tx, sessionId, window.ID); err != nil {
-			return err
+		deleted, err := deletePaneBucket(tx, window)
+		if err != nil {
+			return fmt.Errorf("delete panes of window %s: %w", window.ID, err)
 		}
 
+		publishOnCommit(tx, WindowEvents, WindowEvent{Type: WindowDeleted, Window: window})
+
+		windows++
+		panes += deleted
+
 		return nil
 	}); err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	if err := bucket.DeleteBucket([]byte(session.ID.String())); err != nil {
-		return err
+		return 0, 0, err
 	}
 
-	return nil
+	return windows, panes, nil
 }
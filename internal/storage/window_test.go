@@ -0,0 +1,841 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestWindowActivity(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "activity")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var windowIDs []uuid.UUID
+		for range 2 {
+			window, err := NewWindow(tx, session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			windowIDs = append(windowIDs, window.ID)
+		}
+
+		if err := SetActiveWindow(tx, session.ID, windowIDs[0]); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := MarkWindowActivity(tx, session.ID, windowIDs[1]); err != nil {
+			t.Fatal(err)
+		}
+		window, err := GetWindow(tx, session.ID, windowIDs[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !window.HasActivity {
+			t.Fatal("expected window to have activity")
+		}
+
+		if err := SetActiveWindow(tx, session.ID, windowIDs[1]); err != nil {
+			t.Fatal(err)
+		}
+		window, err = GetWindow(tx, session.ID, windowIDs[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if window.HasActivity {
+			t.Fatal("expected activity to be cleared on the active window")
+		}
+
+		session, err = GetSession(tx, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.ActiveWindowID != windowIDs[1] {
+			t.Fatalf("expected active window %s, got %s", windowIDs[1], session.ActiveWindowID)
+		}
+
+		if err := MarkWindowActivity(tx, session.ID, windowIDs[1]); err != nil {
+			t.Fatal(err)
+		}
+		window, err = GetWindow(tx, session.ID, windowIDs[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if window.HasActivity {
+			t.Fatal("expected the active window not to be flagged")
+		}
+
+		return nil
+	})
+}
+
+func TestMaxWindowsPerSession(t *testing.T) {
+	db := openTestDB(t)
+
+	MaxWindowsPerSession = 2
+	t.Cleanup(func() { MaxWindowsPerSession = 0 })
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "limits")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for range 2 {
+			if _, err := NewWindow(tx, session.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if _, err := NewWindow(tx, session.ID); !errors.Is(err, ErrWindowLimitReached) {
+			t.Fatalf("expected ErrWindowLimitReached, got %v", err)
+		}
+
+		count, err := CountWindows(tx, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 2 {
+			t.Fatalf("expected 2 windows, got %d", count)
+		}
+
+		return nil
+	})
+}
+
+func TestCheckWindowIndices(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "indices")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var windows []WindowEntry
+		for i := range 3 {
+			window, err := NewWindow(tx, session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Index from NewWindow is not reliable within one transaction.
+			window.Index = i
+			if err := putWindow(tx, window); err != nil {
+				t.Fatal(err)
+			}
+			windows = append(windows, window)
+		}
+
+		if err := checkWindowIndices(tx, session.ID); err != nil {
+			t.Fatalf("expected valid indices, got %v", err)
+		}
+
+		windows[2].Index = 0
+		if err := putWindow(tx, windows[2]); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkWindowIndices(tx, session.ID); !errors.Is(err, ErrWindowIndexCorrupt) {
+			t.Fatalf("expected ErrWindowIndexCorrupt for duplicate index, got %v", err)
+		}
+
+		windows[2].Index = -1
+		if err := putWindow(tx, windows[2]); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkWindowIndices(tx, session.ID); !errors.Is(err, ErrWindowIndexCorrupt) {
+			t.Fatalf("expected ErrWindowIndexCorrupt for negative index, got %v", err)
+		}
+
+		if err := ReindexWindows(tx, session.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkWindowIndices(tx, session.ID); err != nil {
+			t.Fatalf("expected reindex to repair indices, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestCloneWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		_, err := NewPanes(tx, sessionID, windowID, []PaneSpec{
+			{Width: 40, Height: 24, X: 0, Y: 0, Cwd: "/src"},
+			{Width: 40, Height: 24, X: 40, Y: 0, Cwd: "/tmp"},
+		})
+		return err
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		source, err := GetWindow(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clone, err := CloneWindow(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if clone.ID == source.ID {
+			t.Fatal("expected clone to get a new id")
+		}
+		if clone.Index != 1 {
+			t.Fatalf("expected clone at index 1, got %d", clone.Index)
+		}
+		if clone.Name != source.Name+" (copy)" {
+			t.Fatalf("unexpected clone name %q", clone.Name)
+		}
+
+		stored, err := GetWindow(tx, sessionID, clone.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Name != clone.Name {
+			t.Fatalf("expected stored name %q, got %q", clone.Name, stored.Name)
+		}
+
+		sourcePanes, err := GetPanes(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		clonePanes, err := GetPanes(tx, sessionID, clone.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(clonePanes) != 2 {
+			t.Fatalf("expected 2 cloned panes, got %d", len(clonePanes))
+		}
+
+		byCwd := make(map[string]PaneEntry)
+		for _, pane := range sourcePanes {
+			byCwd[pane.Cwd] = pane
+		}
+		for _, pane := range clonePanes {
+			original, ok := byCwd[pane.Cwd]
+			if !ok {
+				t.Fatalf("unexpected cloned pane cwd %q", pane.Cwd)
+			}
+			if pane.ID == original.ID {
+				t.Fatal("expected cloned pane to get a new id")
+			}
+			if pane.X != original.X || pane.Y != original.Y || pane.Width != original.Width || pane.Height != original.Height {
+				t.Fatalf("cloned pane geometry differs: %+v vs %+v", pane, original)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestCloneWindowToSession(t *testing.T) {
+	db := openTestDB(t)
+	srcID := buildTree(t, db, "clone-src")
+	dstID := buildTree(t, db, "clone-dst")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		source, err := GetWindowByIndex(tx, srcID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clone, err := CloneWindowToSession(tx, srcID, source.ID, dstID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if clone.SessionID != dstID || clone.Index != 2 || clone.Name != source.Name {
+			t.Fatalf("expected %q at the end of the destination, got %+v", source.Name, clone)
+		}
+
+		for _, check := range []struct {
+			sessionID, windowID uuid.UUID
+		}{{srcID, source.ID}, {dstID, clone.ID}} {
+			panes, err := GetPanes(tx, check.sessionID, check.windowID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(panes) != 2 {
+				t.Fatalf("expected 2 panes in window %s, got %d", check.windowID, len(panes))
+			}
+			for _, pane := range panes {
+				if pane.SsessionID != check.sessionID {
+					t.Fatalf("expected pane %s in session %s, got %s", pane.ID, check.sessionID, pane.SsessionID)
+				}
+			}
+		}
+
+		if _, err := CloneWindowToSession(tx, srcID, source.ID, uuid.New()); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected ErrSessionNotFound for a missing destination, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestMoveWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "move")
+
+	var ids []uuid.UUID
+	for range 2 {
+		withTx(t, db, func(tx *bbolt.Tx) error {
+			window, err := NewWindow(tx, sessionID)
+			ids = append(ids, window.ID)
+			return err
+		})
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		// The last window goes to the front; the others shift right.
+		if err := MoveWindow(tx, sessionID, ids[1], 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkWindowIndices(tx, sessionID); err != nil {
+			t.Fatal(err)
+		}
+
+		moved, err := GetWindow(tx, sessionID, ids[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved.Index != 0 {
+			t.Fatalf("expected moved window at index 0, got %d", moved.Index)
+		}
+
+		shifted, err := GetWindow(tx, sessionID, ids[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if shifted.Index != 3 {
+			t.Fatalf("expected window to shift to index 3, got %d", shifted.Index)
+		}
+
+		return nil
+	})
+}
+
+func TestMoveWindowOutOfRange(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "move-range")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		windows, err := GetWindowsWithCounts(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := windows[0].ID
+
+		for _, index := range []int{-1, len(windows)} {
+			err := MoveWindow(tx, sessionID, first, index)
+			if !errors.Is(err, ErrIndexOutOfRange) {
+				t.Fatalf("index %d: expected ErrIndexOutOfRange, got %v", index, err)
+			}
+			if !strings.Contains(err.Error(), "0..1") {
+				t.Fatalf("index %d: expected the valid range in %q", index, err)
+			}
+		}
+
+		if err := MoveWindow(tx, sessionID, first, len(windows)-1); err != nil {
+			t.Fatal(err)
+		}
+		moved, err := GetWindow(tx, sessionID, first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved.Index != len(windows)-1 {
+			t.Fatalf("expected index %d, got %d", len(windows)-1, moved.Index)
+		}
+
+		return checkWindowIndices(tx, sessionID)
+	})
+}
+
+func TestRenameWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := RenameWindow(tx, sessionID, windowID, "  "); !errors.Is(err, ErrEmptyWindowName) {
+			t.Fatalf("expected ErrEmptyWindowName, got %v", err)
+		}
+		if err := RenameWindow(tx, sessionID, windowID, " editor "); err != nil {
+			t.Fatal(err)
+		}
+
+		window, err := GetWindow(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if window.Name != "editor" {
+			t.Fatalf("expected name %q, got %q", "editor", window.Name)
+		}
+
+		return nil
+	})
+}
+
+func TestWindowNameCollision(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	var existing string
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		window, err := GetWindow(tx, sessionID, windowID)
+		existing = window.Name
+		return err
+	})
+
+	original := generateWindowName
+	t.Cleanup(func() { generateWindowName = original })
+
+	names := []string{existing, existing, "Window-fresh"}
+	generateWindowName = func() (string, error) {
+		name := names[0]
+		names = names[1:]
+		return name, nil
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		window, err := NewWindow(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if window.Name != "Window-fresh" {
+			t.Fatalf("expected colliding names to be retried, got %q", window.Name)
+		}
+		return nil
+	})
+
+	generateWindowName = func() (string, error) { return existing, nil }
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := NewWindow(tx, sessionID); !errors.Is(err, ErrNameGenerationFailed) {
+			t.Fatalf("expected ErrNameGenerationFailed, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestGetWindowsWithCounts(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "counts")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		_, err := NewWindow(tx, sessionID)
+		return err
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		windows, err := GetWindowsWithCounts(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []int{2, 2, 0}
+		if len(windows) != len(expected) {
+			t.Fatalf("expected %d windows, got %d", len(expected), len(windows))
+		}
+		for i, window := range windows {
+			if window.Index != i {
+				t.Fatalf("expected windows ordered by index, got %d at %d", window.Index, i)
+			}
+			if window.PaneCount != expected[i] {
+				t.Fatalf("window %d: expected %d panes, got %d", i, expected[i], window.PaneCount)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestGetLastActiveWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "mru")
+
+	var third WindowEntry
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		third, err = NewWindow(tx, sessionID)
+		return err
+	})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stubClock(t, start, start, start, start.Add(time.Minute), start.Add(time.Minute), start.Add(time.Minute),
+		start.Add(2*time.Minute), start.Add(2*time.Minute), start.Add(2*time.Minute),
+		start.Add(3*time.Minute))
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if _, err := GetLastActiveWindow(tx, sessionID); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("expected ErrWindowNotFound before any switch, got %v", err)
+		}
+
+		windows, err := GetWindowsWithCounts(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first, second := windows[0].ID, windows[1].ID
+
+		for _, id := range []uuid.UUID{first, second, third.ID} {
+			if err := SetActiveWindow(tx, sessionID, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		last, err := GetLastActiveWindow(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.ID != second {
+			t.Fatalf("expected second window as last active, got index %d", last.Index)
+		}
+
+		// Toggling back makes the third window the last active one.
+		if err := SetActiveWindow(tx, sessionID, second); err != nil {
+			t.Fatal(err)
+		}
+		last, err = GetLastActiveWindow(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last.ID != third.ID {
+			t.Fatalf("expected third window as last active, got index %d", last.Index)
+		}
+
+		return nil
+	})
+}
+
+func TestDeleteMissingWindow(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		return DeleteWindow(tx, sessionID, uuid.New())
+	})
+	if !errors.Is(err, ErrWindowNotFound) {
+		t.Fatalf("expected ErrWindowNotFound, got %v", err)
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := DeleteWindow(tx, sessionID, windowID); err != nil {
+			t.Fatal(err)
+		}
+		if err := DeleteWindow(tx, sessionID, windowID); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("expected ErrWindowNotFound on second delete, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestGetWindowsByCreation(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, first := seedWindow(t, db)
+
+	created := []uuid.UUID{first}
+	for range 2 {
+		withTx(t, db, func(tx *bbolt.Tx) error {
+			window, err := NewWindow(tx, sessionID)
+			created = append(created, window.ID)
+			return err
+		})
+	}
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		if err := MoveWindow(tx, sessionID, created[2], 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := MoveWindow(tx, sessionID, created[0], 2); err != nil {
+			t.Fatal(err)
+		}
+
+		windows, err := GetWindowsByCreation(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(windows) != len(created) {
+			t.Fatalf("expected %d windows, got %d", len(created), len(windows))
+		}
+		for i, window := range windows {
+			if window.ID != created[i] {
+				t.Fatalf("expected window %d to be %s, got %s (seq %d)", i, created[i], window.ID, window.Seq)
+			}
+			if window.Seq != int64(i+1) {
+				t.Fatalf("expected seq %d, got %d", i+1, window.Seq)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestNewWindowIndexAfterDelete(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "gaps")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var windows []WindowEntry
+		for range 3 {
+			window, err := NewWindow(tx, session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			windows = append(windows, window)
+		}
+
+		if err := DeleteWindow(tx, session.ID, windows[1].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		fourth, err := NewWindow(tx, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fourth.Index != 3 {
+			t.Fatalf("expected the new window at index 3, got %d", fourth.Index)
+		}
+
+		all, err := GetWindows(tx, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[int]bool)
+		for _, window := range all {
+			if seen[window.Index] {
+				t.Fatalf("index %d used twice", window.Index)
+			}
+			seen[window.Index] = true
+		}
+
+		return nil
+	})
+}
+
+func TestDeleteWindowIfEmpty(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		pane, err := NewPane(tx, sessionID, windowID, 80, 24, 0, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := DeleteWindowIfEmpty(tx, sessionID, windowID); !errors.Is(err, ErrWindowNotEmpty) {
+			t.Fatalf("expected ErrWindowNotEmpty, got %v", err)
+		}
+		if _, err := GetPane(tx, sessionID, windowID, pane.ID); err != nil {
+			t.Fatalf("expected the pane to survive a refused delete, got %v", err)
+		}
+
+		if err := DeletePane(tx, sessionID, windowID, pane.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := DeleteWindowIfEmpty(tx, sessionID, windowID); err != nil {
+			t.Fatalf("expected an empty window to be deleted, got %v", err)
+		}
+		if _, err := GetWindow(tx, sessionID, windowID); !errors.Is(err, ErrWindowNotFound) {
+			t.Fatalf("expected ErrWindowNotFound, got %v", err)
+		}
+
+		return nil
+	})
+}
+
+func TestDeleteWindowCascadesPanes(t *testing.T) {
+	db := openTestDB(t)
+	sessionID := buildTree(t, db, "cascade")
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		windows, err := GetWindows(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := DeleteWindow(tx, sessionID, windows[0].ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if tx.Bucket(PaneBucket).Bucket([]byte(windows[0].ID.String())) != nil {
+			t.Fatal("expected the window's panes to be deleted with it")
+		}
+
+		panes, err := GetAllPanes(tx, sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(panes) != 2 {
+			t.Fatalf("expected the other window's 2 panes to remain, got %d", len(panes))
+		}
+
+		return nil
+	})
+}
+
+func TestNewWindowAt(t *testing.T) {
+	db := openTestDB(t)
+
+	var sessionID uuid.UUID
+	var original []uuid.UUID
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "insert-at")
+		if err != nil {
+			return err
+		}
+		sessionID = session.ID
+
+		for range 3 {
+			window, err := NewWindow(tx, sessionID)
+			if err != nil {
+				return err
+			}
+			original = append(original, window.ID)
+		}
+		return nil
+	})
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		inserted, err := NewWindowAt(tx, sessionID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted.Index != 1 {
+			t.Fatalf("expected the new window at index 1, got %d", inserted.Index)
+		}
+
+		want := []uuid.UUID{original[0], inserted.ID, original[1], original[2]}
+		for i, id := range want {
+			window, err := GetWindowByIndex(tx, sessionID, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if window.ID != id {
+				t.Fatalf("expected %s at index %d, got %s", id, i, window.ID)
+			}
+		}
+
+		if _, err := NewWindowAt(tx, sessionID, 5); !errors.Is(err, ErrIndexOutOfRange) {
+			t.Fatalf("expected ErrIndexOutOfRange past the end, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestGeneratedWindowNamesAreSafe(t *testing.T) {
+	safe := regexp.MustCompile(`^Window-[A-Za-z0-9_-]+$`)
+
+	for range 1000 {
+		name, err := generateWindowName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !safe.MatchString(name) {
+			t.Fatalf("generated an unsafe window name %q", name)
+		}
+	}
+
+	original := WindowNameAlphabet
+	t.Cleanup(func() { WindowNameAlphabet = original })
+
+	for _, alphabet := range []string{"ab cd", "ab\ncd", "ab\x00", "a/b", "x"} {
+		WindowNameAlphabet = alphabet
+		if _, err := generateWindowName(); !errors.Is(err, ErrInvalidWindowNameAlphabet) {
+			t.Fatalf("expected ErrInvalidWindowNameAlphabet for %q, got %v", alphabet, err)
+		}
+	}
+}
+
+func TestGetWindowsWithoutWindows(t *testing.T) {
+	db := openTestDB(t)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		session, err := NewSession(tx, "windowless")
+		if err != nil {
+			return err
+		}
+
+		windows, err := GetWindows(tx, session.ID)
+		if err != nil {
+			t.Fatalf("expected no error for a session without windows, got %v", err)
+		}
+		if len(windows) != 0 {
+			t.Fatalf("expected no windows, got %d", len(windows))
+		}
+
+		if _, err := GetWindows(tx, uuid.New()); !errors.Is(err, ErrSessionNotFound) {
+			t.Fatalf("expected ErrSessionNotFound for an unknown session, got %v", err)
+		}
+		return nil
+	})
+}
+
+func TestCloneWindowPublishesFinalName(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	events, cancel := WindowEvents.Subscribe(8)
+	defer cancel()
+
+	var clone WindowEntry
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		var err error
+		clone, err = CloneWindow(tx, sessionID, windowID)
+		return err
+	})
+
+	select {
+	case event := <-events:
+		if event.Type != WindowCreated || event.Window.ID != clone.ID {
+			t.Fatalf("expected WindowCreated for the clone, got %+v", event)
+		}
+		if event.Window.Name != clone.Name {
+			t.Fatalf("expected the event to carry the clone's name %q, got %q", clone.Name, event.Window.Name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a WindowCreated event")
+	}
+}
+
+func TestNewWindowDecodesWindowsOnce(t *testing.T) {
+	db := openTestDB(t)
+	store := NewStore(db)
+	sessionID, _ := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		for range 4 {
+			if _, err := NewWindow(tx, sessionID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	codec := &countingCodec{}
+	store.SetCodec(codec)
+	t.Cleanup(func() { store.SetCodec(nil) })
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		_, err := NewWindow(tx, sessionID)
+		return err
+	})
+
+	// Each entry decodes its header and then its body: the session once,
+	// plus each of the 5 windows in a single scan.
+	if got, want := codec.unmarshals.Load(), int64(2*(1+5)); got != want {
+		t.Fatalf("expected %d decodes, got %d", want, got)
+	}
+}
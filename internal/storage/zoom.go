@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+// SetPaneZoom zooms or unzooms a pane and keeps the window's ZoomedPaneID in
+// step. A window has at most one zoomed pane, so zooming a pane unzooms the
+// one zoomed before it.
+func SetPaneZoom(tx *bbolt.Tx, sessionId, windowId, paneId uuid.UUID, zoomed bool) error {
+	if err := writableTx(tx); err != nil {
+		return err
+	}
+
+	window, err := GetWindow(tx, sessionId, windowId)
+	if err != nil {
+		return err
+	}
+
+	pane, err := GetPane(tx, sessionId, window.ID, paneId)
+	if err != nil {
+		return err
+	}
+
+	if zoomed && window.ZoomedPaneID != uuid.Nil && window.ZoomedPaneID != pane.ID {
+		previous, err := GetPane(tx, sessionId, window.ID, window.ZoomedPaneID)
+		if err != nil {
+			return err
+		}
+
+		previous.Zoomed, previous.UpdatedAt = false, now()
+		if err := putPane(tx, previous); err != nil {
+			return err
+		}
+	}
+
+	pane.Zoomed, pane.UpdatedAt = zoomed, now()
+	if err := putPane(tx, pane); err != nil {
+		return err
+	}
+
+	switch {
+	case zoomed:
+		window.ZoomedPaneID = pane.ID
+	case window.ZoomedPaneID == pane.ID:
+		window.ZoomedPaneID = uuid.Nil
+	default:
+		return nil
+	}
+	window.UpdatedAt = now()
+
+	return putWindow(tx, window)
+}
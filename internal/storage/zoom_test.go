@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"go.etcd.io/bbolt"
+)
+
+func TestSetPaneZoom(t *testing.T) {
+	db := openTestDB(t)
+	sessionID, windowID := seedWindow(t, db)
+
+	withTx(t, db, func(tx *bbolt.Tx) error {
+		first, err := NewPane(tx, sessionID, windowID, 40, 24, 0, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := NewPane(tx, sessionID, windowID, 40, 24, 40, 0, "/tmp")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectZoomed := func(want uuid.UUID) {
+			t.Helper()
+			window, err := GetWindow(tx, sessionID, windowID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if window.ZoomedPaneID != want {
+				t.Fatalf("expected zoomed pane %s, got %s", want, window.ZoomedPaneID)
+			}
+			for _, id := range []uuid.UUID{first.ID, second.ID} {
+				pane, err := GetPane(tx, sessionID, windowID, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if pane.Zoomed != (id == want) {
+					t.Fatalf("pane %s: expected zoomed=%v, got %v", id, id == want, pane.Zoomed)
+				}
+			}
+		}
+
+		if err := SetPaneZoom(tx, sessionID, windowID, first.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		expectZoomed(first.ID)
+
+		// Zooming another pane moves the zoom to it.
+		if err := SetPaneZoom(tx, sessionID, windowID, second.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		expectZoomed(second.ID)
+
+		// Unzooming a pane that is not zoomed leaves the pointer alone.
+		if err := SetPaneZoom(tx, sessionID, windowID, first.ID, false); err != nil {
+			t.Fatal(err)
+		}
+		expectZoomed(second.ID)
+
+		if err := SetPaneZoom(tx, sessionID, windowID, second.ID, false); err != nil {
+			t.Fatal(err)
+		}
+		expectZoomed(uuid.Nil)
+
+		// Deleting the zoomed pane clears the pointer.
+		if err := SetPaneZoom(tx, sessionID, windowID, first.ID, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := DeletePane(tx, sessionID, windowID, first.ID); err != nil {
+			t.Fatal(err)
+		}
+		window, err := GetWindow(tx, sessionID, windowID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if window.ZoomedPaneID != uuid.Nil {
+			t.Fatalf("expected no zoomed pane after delete, got %s", window.ZoomedPaneID)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+// Package terminal holds the live I/O of running panes: where typed input goes
+// and the output clients attached to a pane receive.
+package terminal
+
+import (
+	"io"
+	"sync"
+
+	"github.com/cchirag/ira/internal/events"
+	"github.com/google/uuid"
+)
+
+// Buffer fans a pane's output out to every attached client. Like the event
+// broker it never blocks the writer; a client that falls behind its buffer
+// drops frames.
+type Buffer struct {
+	broker *events.Broker[[]byte]
+}
+
+func NewBuffer() *Buffer {
+	return &Buffer{broker: events.NewBroker[[]byte]()}
+}
+
+// Write publishes a copy of p to every subscriber.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.broker.Publish(append([]byte(nil), p...))
+	return len(p), nil
+}
+
+// Subscribe returns a channel of output frames and a func that stops it.
+func (b *Buffer) Subscribe(buffer int) (<-chan []byte, func()) {
+	return b.broker.Subscribe(buffer)
+}
+
+// Pane is the I/O of one running pane. Input receives what attached clients
+// type; whatever the pane prints is written to Output.
+type Pane struct {
+	Input  io.Writer
+	Output *Buffer
+}
+
+// Registry maps pane IDs to their running I/O. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	panes map[uuid.UUID]*Pane
+}
+
+func NewRegistry() *Registry {
+	return &Registry{panes: make(map[uuid.UUID]*Pane)}
+}
+
+func (r *Registry) Register(id uuid.UUID, pane *Pane) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.panes[id] = pane
+}
+
+func (r *Registry) Unregister(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.panes, id)
+}
+
+func (r *Registry) Get(id uuid.UUID) (*Pane, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pane, ok := r.panes[id]
+	return pane, ok
+}
@@ -0,0 +1,24 @@
+package terminal
+
+import "testing"
+
+func TestBufferFansOut(t *testing.T) {
+	buffer := NewBuffer()
+
+	a, cancelA := buffer.Subscribe(1)
+	defer cancelA()
+	b, cancelB := buffer.Subscribe(1)
+	defer cancelB()
+
+	frame := []byte("output")
+	if _, err := buffer.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	frame[0] = 'X'
+
+	for _, ch := range []<-chan []byte{a, b} {
+		if got := string(<-ch); got != "output" {
+			t.Fatalf("expected a copy of the written frame, got %q", got)
+		}
+	}
+}